@@ -0,0 +1,134 @@
+package telegram
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "user-handler-bot/helpers"
+)
+
+// MessageContent is everything needed to send a message again without the original one,
+// a single media has one item in Media and a media group has several
+type MessageContent struct {
+    Text      string          `json:"text,omitempty"`
+    Entities  []MessageEntity `json:"entities,omitempty"`
+    ParseMode string          `json:"parse_mode,omitempty"`
+    Media     []InputMedia    `json:"media,omitempty"`
+    LinkPreviewOptions *LinkPreviewOptions `json:"link_preview_options,omitempty"`
+}
+
+type InputMedia struct {
+    Type            string          `json:"type"`
+    Media           string          `json:"media"`
+    Caption         string          `json:"caption,omitempty"`
+    CaptionEntities []MessageEntity `json:"caption_entities,omitempty"`
+    ParseMode       string          `json:"parse_mode,omitempty"`
+}
+
+var ErrUnsupportedContent = errors.New("message content is not supported")
+
+// mediaMethods maps a type of media to the method which sends it alone
+var mediaMethods = map[string]string{
+    "photo": "sendPhoto",
+    "video": "sendVideo",
+    "document": "sendDocument",
+    "animation": "sendAnimation",
+    "audio": "sendAudio",
+    "voice": "sendVoice",
+}
+
+// NewMessageContent takes the content of the message, stickers, polls and others are not supported
+func NewMessageContent(message Message) (MessageContent, error) {
+    media := InputMedia{Caption: message.Caption, CaptionEntities: message.CaptionEntities}
+    switch {
+    case len(message.Photo) > 0:
+        // the last size is the biggest one
+        media.Type, media.Media = "photo", message.Photo[len(message.Photo) - 1].FileId
+    case message.Video != nil:
+        media.Type, media.Media = "video", message.Video.FileId
+    case message.Document != nil:
+        media.Type, media.Media = "document", message.Document.FileId
+    case message.Animation != nil:
+        media.Type, media.Media = "animation", message.Animation.FileId
+    case message.Audio != nil:
+        media.Type, media.Media = "audio", message.Audio.FileId
+    case message.Voice != nil:
+        media.Type, media.Media = "voice", message.Voice.FileId
+    case message.Text != "":
+        return MessageContent{Text: message.Text, Entities: message.Entities, LinkPreviewOptions: message.LinkPreviewOptions}, nil
+    default:
+        return MessageContent{}, ErrUnsupportedContent
+    }
+    return MessageContent{Media: []InputMedia{media}}, nil
+}
+
+// SendContent sends the stored content to the chat and returns ids of the sent messages,
+// a media group has one message for every media
+func (c *Client) SendContent(chatId int, content MessageContent, priority Priority) ([]int, error) {
+    request := map[string]interface{}{"chat_id": chatId}
+    method := "sendMessage"
+    switch len(content.Media) {
+    case 0:
+        request["text"] = content.Text
+        if len(content.Entities) > 0 {
+            request["entities"] = content.Entities
+        }
+        if content.ParseMode != "" {
+            request["parse_mode"] = content.ParseMode
+        }
+        if content.LinkPreviewOptions != nil {
+            request["link_preview_options"] = content.LinkPreviewOptions
+        }
+    case 1:
+        media := content.Media[0]
+        var ok bool
+        if method, ok = mediaMethods[media.Type]; !ok {
+            return nil, helpers.WrapErr(ErrUnsupportedContent, "SendContent media type " + media.Type)
+        }
+        request[media.Type] = media.Media
+        if media.Caption != "" {
+            request["caption"] = media.Caption
+        }
+        if media.CaptionEntities != nil {
+            request["caption_entities"] = media.CaptionEntities
+        }
+        if media.ParseMode != "" {
+            request["parse_mode"] = media.ParseMode
+        }
+    default:
+        method = "sendMediaGroup"
+        request["media"] = content.Media
+    }
+
+    data, err := json.Marshal(request)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "SendContent json.Marshal")
+    }
+    c.limiter.wait(context.Background(), chatId, priority)
+    body, err := c.doPostRequest(method, data)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "SendContent " + method + " error")
+    }
+
+    if method == "sendMediaGroup" {
+        var result struct {
+            Messages []Message `json:"result"`
+        }
+        if err := json.Unmarshal(body, &result); err != nil {
+            return nil, helpers.WrapErr(err, "SendContent " + method + " Unmarshal error")
+        }
+        if len(result.Messages) == 0 {
+            return nil, errors.New("SendContent " + method + " returned no messages")
+        }
+        ids := make([]int, 0, len(result.Messages))
+        for _, message := range result.Messages {
+            ids = append(ids, message.Id)
+        }
+        return ids, nil
+    }
+    var result SendMessageResponse
+    if err := json.Unmarshal(body, &result); err != nil {
+        return nil, helpers.WrapErr(err, "SendContent " + method + " Unmarshal error")
+    }
+    return []int{result.Message.Id}, nil
+}
@@ -0,0 +1,76 @@
+package telegram
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+)
+
+// maxRetries is how many times a request is repeated after 429 Too Many Requests
+const maxRetries = 3
+
+var (
+    ErrTooManyRequests      = errors.New("too many requests")
+    ErrBotBlocked           = errors.New("bot was blocked by the user")
+    ErrChatNotFound         = errors.New("chat not found")
+    ErrChatMigrated         = errors.New("group chat was migrated to a supergroup")
+    ErrMessageNotModified   = errors.New("message is not modified")
+    ErrJoinRequestMissing   = errors.New("join request is missing")
+    ErrMessageNotFound      = errors.New("message not found")
+    ErrMessageCantBeChanged = errors.New("message can not be changed")
+    ErrNoTextToEdit         = errors.New("there is no text in the message to edit")
+)
+
+type apiResponse struct {
+    Ok          bool               `json:"ok"`
+    ErrorCode   int                `json:"error_code"`
+    Description string             `json:"description"`
+    Parameters  ResponseParameters `json:"parameters"`
+}
+
+type ResponseParameters struct {
+    MigrateToChatId int `json:"migrate_to_chat_id"`
+    RetryAfter      int `json:"retry_after"`
+}
+
+// APIError is returned by every client method when telegram answers with ok=false,
+// compare it with errors.Is against Err* values to react on a specific failure
+type APIError struct {
+    Method      string
+    Code        int
+    Description string
+    Parameters  ResponseParameters
+}
+
+func (e *APIError) Error() string {
+    return "telegram " + e.Method + " error " + strconv.Itoa(e.Code) + ": " + e.Description
+}
+
+func (e *APIError) Is(target error) bool {
+    description := strings.ToLower(e.Description)
+    switch target {
+    case ErrTooManyRequests:
+        return e.Code == http.StatusTooManyRequests
+    case ErrBotBlocked:
+        return e.Code == http.StatusForbidden && strings.Contains(description, "bot was blocked by the user")
+    case ErrChatNotFound:
+        return e.Code == http.StatusBadRequest && strings.Contains(description, "chat not found")
+    case ErrChatMigrated:
+        return e.Parameters.MigrateToChatId != 0
+    case ErrMessageNotModified:
+        return e.Code == http.StatusBadRequest && strings.Contains(description, "message is not modified")
+    case ErrJoinRequestMissing:
+        return e.Code == http.StatusBadRequest && strings.Contains(description, "hide_requester_missing")
+    case ErrMessageNotFound:
+        return e.Code == http.StatusBadRequest &&
+            (strings.Contains(description, "message to delete not found") || strings.Contains(description, "message to edit not found"))
+    case ErrMessageCantBeChanged:
+        return e.Code == http.StatusBadRequest &&
+            (strings.Contains(description, "message can't be deleted") || strings.Contains(description, "message can't be edited"))
+    case ErrNoTextToEdit:
+        return e.Code == http.StatusBadRequest && strings.Contains(description, "there is no text in the message to edit")
+    default:
+        return false
+    }
+}
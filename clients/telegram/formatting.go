@@ -0,0 +1,43 @@
+package telegram
+
+import "strings"
+
+const (
+    ParseModeHTML       = "HTML"
+    ParseModeMarkdownV2 = "MarkdownV2"
+)
+
+// LinkPreviewOptions controls the preview of the first link of a text message
+type LinkPreviewOptions struct {
+    IsDisabled       bool   `json:"is_disabled,omitempty"`
+    Url              string `json:"url,omitempty"`
+    PreferSmallMedia bool   `json:"prefer_small_media,omitempty"`
+    PreferLargeMedia bool   `json:"prefer_large_media,omitempty"`
+    ShowAboveText    bool   `json:"show_above_text,omitempty"`
+}
+
+// markdownV2Special are characters which must be escaped anywhere in the MarkdownV2 text
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// EscapeMarkdownV2 makes the text safe for the MarkdownV2 parse mode
+func EscapeMarkdownV2(text string) string {
+    var result strings.Builder
+    for _, r := range text {
+        if strings.ContainsRune(markdownV2Special, r) {
+            result.WriteRune('\\')
+        }
+        result.WriteRune(r)
+    }
+    return result.String()
+}
+
+// EscapeMarkdownV2Code makes the text safe inside pre and code entities of MarkdownV2,
+// only ` and \ are special there
+func EscapeMarkdownV2Code(text string) string {
+    return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(text)
+}
+
+// BoldHTML wraps the escaped text into bold for the HTML parse mode
+func BoldHTML(text string) string {
+    return "<b>" + EscapeHTML(text) + "</b>"
+}
@@ -0,0 +1,99 @@
+package telegram
+
+import (
+    "sort"
+    "strconv"
+    "strings"
+    "unicode/utf16"
+)
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// EscapeHTML makes the text safe for the HTML parse mode
+func EscapeHTML(text string) string {
+    return htmlEscaper.Replace(text)
+}
+
+// EntitiesToHTML turns the text with entities into the HTML parse mode, so the text can be changed
+// without recounting offsets of entities. Entities found by telegram itself like urls are left as text
+func EntitiesToHTML(text string, entities []MessageEntity) string {
+    // offsets of entities are counted in UTF-16 code units
+    units := utf16.Encode([]rune(text))
+    sorted := make([]MessageEntity, len(entities))
+    copy(sorted, entities)
+    // an outer entity opens before the inner ones which start at the same place
+    sort.SliceStable(sorted, func(i, j int) bool {
+        if sorted[i].Offset != sorted[j].Offset {
+            return sorted[i].Offset < sorted[j].Offset
+        }
+        return sorted[i].Length > sorted[j].Length
+    })
+
+    opens := make(map[int][]string)
+    closes := make(map[int][]string)
+    for _, entity := range sorted {
+        open, close := entityTags(entity)
+        if open == "" {
+            continue
+        }
+        if entity.Offset < 0 || entity.Offset >= len(units) {
+            continue
+        }
+        end := entity.Offset + entity.Length
+        if end > len(units) {
+            end = len(units)
+        }
+        opens[entity.Offset] = append(opens[entity.Offset], open)
+        // inner entities close first
+        closes[end] = append([]string{close}, closes[end]...)
+    }
+
+    var result strings.Builder
+    start := 0
+    for pos := 0; pos <= len(units); pos++ {
+        if len(opens[pos]) == 0 && len(closes[pos]) == 0 {
+            continue
+        }
+        result.WriteString(EscapeHTML(string(utf16.Decode(units[start:pos]))))
+        result.WriteString(strings.Join(closes[pos], ""))
+        result.WriteString(strings.Join(opens[pos], ""))
+        start = pos
+    }
+    result.WriteString(EscapeHTML(string(utf16.Decode(units[start:]))))
+    return result.String()
+}
+
+func entityTags(entity MessageEntity) (string, string) {
+    switch entity.Type {
+    case "bold":
+        return "<b>", "</b>"
+    case "italic":
+        return "<i>", "</i>"
+    case "underline":
+        return "<u>", "</u>"
+    case "strikethrough":
+        return "<s>", "</s>"
+    case "spoiler":
+        return "<tg-spoiler>", "</tg-spoiler>"
+    case "code":
+        return "<code>", "</code>"
+    case "pre":
+        if entity.Language != "" {
+            return `<pre><code class="language-` + EscapeHTML(entity.Language) + `">`, "</code></pre>"
+        }
+        return "<pre>", "</pre>"
+    case "blockquote":
+        return "<blockquote>", "</blockquote>"
+    case "text_link":
+        return `<a href="` + EscapeHTML(entity.Url) + `">`, "</a>"
+    case "text_mention":
+        if entity.User == nil {
+            return "", ""
+        }
+        return `<a href="tg://user?id=` + strconv.Itoa(entity.User.Id) + `">`, "</a>"
+    case "custom_emoji":
+        return `<tg-emoji emoji-id="` + EscapeHTML(entity.CustomEmojiId) + `">`, "</tg-emoji>"
+    default:
+        return "", ""
+    }
+}
@@ -0,0 +1,220 @@
+package telegram
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// telegram limits for bots: about 30 messages per second overall,
+// no more than one message per second in a private chat and 20 messages per minute in a group
+const (
+    globalRatePerSecond  = 30
+    privateChatRate      = 1
+    privateChatBurst     = 3
+    groupChatRate        = 20.0 / 60.0
+    groupChatBurst       = 20
+    maxChatBuckets       = 10000
+)
+
+type bucket struct {
+    rate   float64
+    burst  float64
+    tokens float64
+    last   time.Time
+}
+
+func newBucket(rate float64, burst float64, now time.Time) *bucket {
+    return &bucket{
+        rate: rate,
+        burst: burst,
+        tokens: burst,
+        last: now,
+    }
+}
+
+// reserve takes one token and returns how long to wait until the token is really available
+func (b *bucket) reserve(now time.Time) time.Duration {
+    b.refill(now)
+    b.tokens--
+    if b.tokens >= 0 {
+        return 0
+    }
+    return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *bucket) refill(now time.Time) {
+    elapsed := now.Sub(b.last).Seconds()
+    b.last = now
+    b.tokens += elapsed * b.rate
+    if b.tokens > b.burst {
+        b.tokens = b.burst
+    }
+}
+
+func (b *bucket) isFull(now time.Time) bool {
+    b.refill(now)
+    return b.tokens >= b.burst
+}
+
+func (b *bucket) take(now time.Time) (bool, time.Duration) {
+    b.refill(now)
+    if b.tokens >= 1 {
+        b.tokens--
+        return true, 0
+    }
+    return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Priority of an outgoing message, when the global limit is exhausted
+// messages with a higher priority are sent first
+type Priority int
+
+const (
+    PriorityAdmin Priority = iota
+    PriorityWelcome
+    PriorityBulk
+    prioritiesCount
+)
+
+type waiter struct {
+    ready    chan struct{}
+    canceled bool
+}
+
+// rateLimiter is shared by all goroutines that send messages through the client
+type rateLimiter struct {
+    mu          sync.Mutex
+    global      *bucket
+    chats       map[int]*bucket
+    lanes       [prioritiesCount][]*waiter
+    dispatching bool
+}
+
+func newRateLimiter() *rateLimiter {
+    return &rateLimiter{
+        global: newBucket(globalRatePerSecond, globalRatePerSecond, time.Now()),
+        chats: make(map[int]*bucket),
+    }
+}
+
+func (l *rateLimiter) wait(ctx context.Context, chatId int, priority Priority) error {
+    if err := sleep(ctx, l.reserveChat(chatId)); err != nil {
+        return err
+    }
+
+    l.mu.Lock()
+    if l.firstWaiter(priority) == nil {
+        if ok, _ := l.global.take(time.Now()); ok {
+            l.mu.Unlock()
+            return nil
+        }
+    }
+    w := &waiter{ready: make(chan struct{})}
+    l.lanes[priority] = append(l.lanes[priority], w)
+    if !l.dispatching {
+        l.dispatching = true
+        go l.dispatch()
+    }
+    l.mu.Unlock()
+
+    select {
+    case <-w.ready:
+        return nil
+    case <-ctx.Done():
+        l.mu.Lock()
+        w.canceled = true
+        l.mu.Unlock()
+        return ctx.Err()
+    }
+}
+
+// dispatch hands out global tokens to waiters starting from the highest priority lane
+func (l *rateLimiter) dispatch() {
+    for {
+        l.mu.Lock()
+        w := l.firstWaiter(prioritiesCount - 1)
+        if w == nil {
+            l.dispatching = false
+            l.mu.Unlock()
+            return
+        }
+        ok, delay := l.global.take(time.Now())
+        if !ok {
+            l.mu.Unlock()
+            time.Sleep(delay)
+            continue
+        }
+        l.removeWaiter(w)
+        close(w.ready)
+        l.mu.Unlock()
+    }
+}
+
+// firstWaiter returns the oldest waiter from lanes with the same or a higher priority
+func (l *rateLimiter) firstWaiter(priority Priority) *waiter {
+    for p := PriorityAdmin; p <= priority; p++ {
+        for len(l.lanes[p]) > 0 && l.lanes[p][0].canceled {
+            l.lanes[p] = l.lanes[p][1:]
+        }
+        if len(l.lanes[p]) > 0 {
+            return l.lanes[p][0]
+        }
+    }
+    return nil
+}
+
+func (l *rateLimiter) removeWaiter(w *waiter) {
+    for p := range l.lanes {
+        if len(l.lanes[p]) > 0 && l.lanes[p][0] == w {
+            l.lanes[p] = l.lanes[p][1:]
+            return
+        }
+    }
+}
+
+func (l *rateLimiter) reserveChat(chatId int) time.Duration {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    now := time.Now()
+    chat, ok := l.chats[chatId]
+    if !ok {
+        l.removeIdleChats(now)
+        chat = newChatBucket(chatId, now)
+        l.chats[chatId] = chat
+    }
+    return chat.reserve(now)
+}
+
+func (l *rateLimiter) removeIdleChats(now time.Time) {
+    if len(l.chats) < maxChatBuckets {
+        return
+    }
+    for chatId, chat := range l.chats {
+        if chat.isFull(now) {
+            delete(l.chats, chatId)
+        }
+    }
+}
+
+func newChatBucket(chatId int, now time.Time) *bucket {
+    // groups and channels have negative ids
+    if chatId < 0 {
+        return newBucket(groupChatRate, groupChatBurst, now)
+    }
+    return newBucket(privateChatRate, privateChatBurst, now)
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+    if delay <= 0 {
+        return nil
+    }
+    timer := time.NewTimer(delay)
+    defer timer.Stop()
+    select {
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-timer.C:
+        return nil
+    }
+}
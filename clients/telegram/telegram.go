@@ -2,23 +2,31 @@ package telegram
 
 import (
     "bytes"
+    "context"
     "encoding/json"
+    "errors"
     "io"
-    "io/ioutil"
     "log"
     "net/http"
     "net/url"
     "path"
     "strconv"
     "strings"
+    "time"
     "user-handler-bot/helpers"
 )
 
+// requestTimeout is a deadline for a single API call, long polling calls get it on top of the poll timeout
+const requestTimeout = 15 * time.Second
+
+var allowedUpdates = []string{"message", "callback_query", "chat_join_request"}
+
 type Client struct {
     host                     string
     botEndpoint              string
     client                   http.Client
     AdminsId                 []int
+    limiter                  *rateLimiter
     lastInlineKeyBoardId     int
     lastInlineKeyBoardChatId int
 }
@@ -29,6 +37,7 @@ func New(host string, botToken string, admins string) Client {
         botEndpoint: getBotEndpoint(botToken),
         client: http.Client{},
         AdminsId: getAdminsIds(admins),
+        limiter: newRateLimiter(),
     }
 }
 
@@ -58,15 +67,60 @@ type SendMessageResponse struct {
     Message  Message `json:"result"`
 }
 
+type CopyMessageResponse struct {
+    Ok      bool      `json:"ok"`
+    Result  MessageId `json:"result"`
+}
+
+type MessageId struct {
+    MessageId int `json:"message_id"`
+}
+
 type Message struct {
     Text    string `json:"text"`
     From    User   `json:"from"`
     Chat    Chat   `json:"chat"`
     Id      int    `json:"message_id"`
+    Entities        []MessageEntity `json:"entities"`
+    Caption         string          `json:"caption"`
+    CaptionEntities []MessageEntity `json:"caption_entities"`
+    Photo           []File          `json:"photo"`
+    Video           *File           `json:"video"`
+    Document        *File           `json:"document"`
+    Animation       *File           `json:"animation"`
+    Audio           *File           `json:"audio"`
+    Voice           *File           `json:"voice"`
+    MediaGroupId    string          `json:"media_group_id"`
+    LinkPreviewOptions *LinkPreviewOptions `json:"link_preview_options"`
+}
+
+// File is any file attached to a message, photos come as several sizes of the same picture
+type File struct {
+    FileId string `json:"file_id"`
+}
+
+type MessageEntity struct {
+    Type          string `json:"type"`
+    Offset        int    `json:"offset"`
+    Length        int    `json:"length"`
+    Url           string `json:"url,omitempty"`
+    User          *User  `json:"user,omitempty"`
+    Language      string `json:"language,omitempty"`
+    CustomEmojiId string `json:"custom_emoji_id,omitempty"`
 }
 
 type Chat struct {
     Id  int `json:"id"`
+    // Title is set for groups and channels, names are set for private chats
+    Title     string `json:"title,omitempty"`
+    FirstName string `json:"first_name,omitempty"`
+    LastName  string `json:"last_name,omitempty"`
+    Username  string `json:"username,omitempty"`
+}
+
+type ChatResponse struct {
+    Ok      bool `json:"ok"`
+    Result  Chat `json:"result"`
 }
 
 type Update struct {
@@ -79,6 +133,11 @@ type Update struct {
 type ChatJoinRequest struct {
     User    User   `json:"from"`
     Chat    Chat   `json:"chat"`
+    Bio     string `json:"bio,omitempty"`
+    // UserChatId is the private chat where the bot can write to the user until the request is decided
+    UserChatId int `json:"user_chat_id,omitempty"`
+    // Date is a unix time when the request was sent
+    Date    int    `json:"date,omitempty"`
 }
 
 type User struct {
@@ -87,6 +146,7 @@ type User struct {
     FirstName string `json:"first_name"`
     LastName  string `json:"last_name"`
     Username  string `json:"username"`
+    LanguageCode string `json:"language_code,omitempty"`
 }
 
 type ChatMember struct {
@@ -117,6 +177,11 @@ type SendMessageRequest struct {
     Text        string                `json:"text"`
     ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
     MessageId   int                   `json:"message_id,omitempty"`
+    // ParseMode and Entities are the two ways to format the text, telegram does not accept both at once
+    ParseMode           string              `json:"parse_mode,omitempty"`
+    Entities            []MessageEntity     `json:"entities,omitempty"`
+    LinkPreviewOptions  *LinkPreviewOptions `json:"link_preview_options,omitempty"`
+    DisableNotification bool                `json:"disable_notification,omitempty"`
 }
 
 type CallbackQuery struct {
@@ -126,13 +191,19 @@ type CallbackQuery struct {
 }
 
 
-func (c *Client) GetUpdate(offset int, limit int) (updates []Update, err error) {
+func (c *Client) GetUpdate(ctx context.Context, offset int, limit int, timeout int) (updates []Update, err error) {
     query := url.Values{}
-    allowedUpdates, _ := json.Marshal([]string{"message", "callback_query", "chat_join_request"})
+    allowedUpdatesJson, _ := json.Marshal(allowedUpdates)
     query.Add("offset", strconv.Itoa(offset))
     query.Add("limit", strconv.Itoa(limit))
-    query.Add("allowed_updates", string(allowedUpdates))
-    data, err := c.doGetRequest("getUpdates", query)
+    query.Add("timeout", strconv.Itoa(timeout))
+    query.Add("allowed_updates", string(allowedUpdatesJson))
+    data, err := c.doGetRequestWithContext(
+        ctx,
+        time.Duration(timeout) * time.Second + requestTimeout,
+        "getUpdates",
+        query,
+    )
     if err != nil {
         return nil, helpers.WrapErr(err, "Telegram API getUpdate error")
     }
@@ -143,6 +214,41 @@ func (c *Client) GetUpdate(offset int, limit int) (updates []Update, err error)
     return result.Result, nil
 }
 
+func (c *Client) SetWebhook(webhookUrl string, secretToken string) error {
+    query := url.Values{}
+    allowedUpdatesJson, _ := json.Marshal(allowedUpdates)
+    query.Add("url", webhookUrl)
+    query.Add("secret_token", secretToken)
+    query.Add("allowed_updates", string(allowedUpdatesJson))
+
+    _, err := c.doGetRequest("setWebhook", query)
+
+    return helpers.WrapErr(err, "setWebhook error")
+}
+
+func (c *Client) DeleteWebhook() error {
+    query := url.Values{}
+    query.Add("drop_pending_updates", "false")
+
+    _, err := c.doGetRequest("deleteWebhook", query)
+
+    return helpers.WrapErr(err, "deleteWebhook error")
+}
+
+func (c *Client) GetChat(chatId int) (Chat, error) {
+    query := url.Values{}
+    query.Add("chat_id", strconv.Itoa(chatId))
+    data, err := c.doGetRequest("getChat", query)
+    if err != nil {
+        return Chat{}, helpers.WrapErr(err, "Telegram API getChat error")
+    }
+    var result ChatResponse
+    if err := json.Unmarshal(data, &result); err != nil {
+        return Chat{}, helpers.WrapErr(err, "getChat Unmarshal error")
+    }
+    return result.Result, nil
+}
+
 func (c *Client) GetChatMember(userId int, chatId int) (user ChatMemberMember, err error) {
     query := url.Values{}
     query.Add("chat_id", strconv.Itoa(chatId))
@@ -173,28 +279,51 @@ func (c *Client) ApproveChatJoinRequest(userId int, chatId int) (ok bool, err er
     return result.Result, nil
 }
 
-func (c *Client) SendMessage(chatId int, text string) error {
+func (c *Client) DeclineChatJoinRequest(userId int, chatId int) (ok bool, err error) {
     query := url.Values{}
     query.Add("chat_id", strconv.Itoa(chatId))
-    query.Add("text", text)
+    query.Add("user_id", strconv.Itoa(userId))
+    data, err := c.doGetRequest("declineChatJoinRequest", query)
+    if err != nil {
+        return false, helpers.WrapErr(err, "Telegram API declineChatJoinRequest error")
+    }
+    var result Approve
+    if err := json.Unmarshal(data, &result); err != nil {
+        return  false, helpers.WrapErr(err, "declineChatJoinRequest Unmarshal error")
+    }
+    return result.Result, nil
+}
 
-    _, err := c.doGetRequest("sendMessage", query)
+func (c *Client) SendMessage(chatId int, text string) error {
+    return c.SendFormattedMessage(SendMessageRequest{ChatID: chatId, Text: text})
+}
+
+// SendFormattedMessage sends the message with its parse mode, entities and other options
+func (c *Client) SendFormattedMessage(msg SendMessageRequest) error {
+    _, err := c.postMessage("sendMessage", msg)
 
     return helpers.WrapErr(err, "sendMessage error")
 }
 
-func (c *Client) ForwardMessage(chatId int, from_chat_id int, forwardMsgId int) error {
+// ForwardMessage copies the message to the chat and returns id of the copy
+func (c *Client) ForwardMessage(chatId int, from_chat_id int, forwardMsgId int, priority Priority) (int, error) {
     query := url.Values{}
     query.Add("chat_id", strconv.Itoa(chatId))
     query.Add("message_id", strconv.Itoa(forwardMsgId))
     query.Add("from_chat_id", strconv.Itoa(from_chat_id))
     query.Add("disable_notification", "")
 
-    res, err := c.doGetRequest("copyMessage", query)
-    //TODO если фиксится отправка убрать лог
-    log.Println(string(res[:]))
+    c.limiter.wait(context.Background(), chatId, priority)
+    data, err := c.doGetRequest("copyMessage", query)
+    if err != nil {
+        return 0, helpers.WrapErr(err, "ForwardMessage error")
+    }
+    var result CopyMessageResponse
+    if err := json.Unmarshal(data, &result); err != nil {
+        return 0, helpers.WrapErr(err, "ForwardMessage Unmarshal error")
+    }
 
-    return helpers.WrapErr(err, "ForwardMessage error")
+    return result.Result.MessageId, nil
 }
 
 func (c *Client) SendInlineKeyBoard(msg SendMessageRequest) error {
@@ -205,7 +334,7 @@ func (c *Client) SendInlineKeyBoard(msg SendMessageRequest) error {
     if c.lastInlineKeyBoardId > 0 && c.lastInlineKeyBoardChatId > 0 {
         c.DeleteMessage(c.lastInlineKeyBoardId, c.lastInlineKeyBoardChatId)
     }
-    return c.sendInlineKeyBoard("sendMessage", jsonData)
+    return c.sendInlineKeyBoard("sendMessage", msg.ChatID, jsonData)
 }
 
 func (c *Client) UpdateInlineKeyBoard(msg SendMessageRequest) error {
@@ -213,37 +342,67 @@ func (c *Client) UpdateInlineKeyBoard(msg SendMessageRequest) error {
     if err != nil {
         return helpers.WrapErr(err, "UpdateInlineKeyBoard json.Marshal")
     }
-    return c.sendInlineKeyBoard("editMessageText", jsonData)
+    err = c.sendInlineKeyBoard("editMessageText", msg.ChatID, jsonData)
+    // pressing the same button twice renders the same keyboard, it is not an error for us
+    if errors.Is(err, ErrMessageNotModified) {
+        return nil
+    }
+    return err
 }
 
-func (c *Client) sendInlineKeyBoard(method string, data []byte) error {
-    query := url.Values{}
-    query.Encode()
-    requestUrl := url.URL{
-        Scheme: "https",
-        Host: c.host,
-        Path: path.Join(c.botEndpoint, method),
+// SendMessageWithKeyBoard sends a message which is not replaced by the next keyboard, like a preview or a progress report,
+// and returns its id
+func (c *Client) SendMessageWithKeyBoard(msg SendMessageRequest) (int, error) {
+    message, err := c.postMessage("sendMessage", msg)
+    if err != nil {
+        return 0, helpers.WrapErr(err, "SendMessageWithKeyBoard error")
     }
-    resp, err := http.Post(
-        requestUrl.String(),
-        "application/json",
-        bytes.NewBuffer(data),
-    )
+    return message.Id, nil
+}
+
+// EditMessageWithKeyBoard edits a message sent by SendMessageWithKeyBoard
+func (c *Client) EditMessageWithKeyBoard(msg SendMessageRequest) error {
+    _, err := c.postMessage("editMessageText", msg)
+    if errors.Is(err, ErrMessageNotModified) {
+        return nil
+    }
+    return helpers.WrapErr(err, "EditMessageWithKeyBoard error")
+}
+
+func (c *Client) postMessage(method string, msg SendMessageRequest) (Message, error) {
+    jsonData, err := json.Marshal(msg)
+    if err != nil {
+        return Message{}, helpers.WrapErr(err, method + " json.Marshal")
+    }
+    c.limiter.wait(context.Background(), msg.ChatID, PriorityAdmin)
+    body, err := c.doPostRequest(method, jsonData)
+    if err != nil {
+        return Message{}, err
+    }
+    var result SendMessageResponse
+    if err := json.Unmarshal(body, &result); err != nil {
+        return Message{}, helpers.WrapErr(err, method + " Unmarshal error")
+    }
+    return result.Message, nil
+}
+
+func (c *Client) sendInlineKeyBoard(method string, chatId int, data []byte) error {
+    c.limiter.wait(context.Background(), chatId, PriorityAdmin)
+    body, err := c.doPostRequest(method, data)
     if err != nil {
-        log.Println(err)
+        return helpers.WrapErr(err, "sendInlineKeyBoard error")
     }
-    defer resp.Body.Close()
-    body, err := ioutil.ReadAll(resp.Body)
     var result SendMessageResponse
     if err := json.Unmarshal(body, &result); err != nil {
-        log.Println(err)
+        log.Println(helpers.WrapErr(err, "sendInlineKeyBoard Unmarshal error"))
+        return nil
     }
-    if err == nil && result.Message.Id > 0 && result.Message.Chat.Id > 0 {
+    if result.Message.Id > 0 && result.Message.Chat.Id > 0 {
         c.lastInlineKeyBoardId = result.Message.Id
         c.lastInlineKeyBoardChatId = result.Message.Chat.Id
     }
 
-    return helpers.WrapErr(err, "doPostRequest error")
+    return nil
 }
 
 func (c *Client) DeleteMessage(messageId int, chatId int) error {
@@ -256,19 +415,111 @@ func (c *Client) DeleteMessage(messageId int, chatId int) error {
     return helpers.WrapErr(err, "deleteMessage error")
 }
 
+// DeleteSentMessage deletes a message the bot sent to the chat, it waits for the rate limiter like sending does
+func (c *Client) DeleteSentMessage(chatId int, messageId int, priority Priority) error {
+    query := url.Values{}
+    query.Add("chat_id", strconv.Itoa(chatId))
+    query.Add("message_id", strconv.Itoa(messageId))
+
+    c.limiter.wait(context.Background(), chatId, priority)
+    _, err := c.doGetRequest("deleteMessage", query)
+
+    return helpers.WrapErr(err, "DeleteSentMessage error")
+}
+
+// EditSentMessageText changes the text of a message the bot sent to the chat, a media message gets the new caption
+func (c *Client) EditSentMessageText(chatId int, messageId int, text string, priority Priority) error {
+    query := url.Values{}
+    query.Add("chat_id", strconv.Itoa(chatId))
+    query.Add("message_id", strconv.Itoa(messageId))
+    query.Add("text", text)
+
+    c.limiter.wait(context.Background(), chatId, priority)
+    _, err := c.doGetRequest("editMessageText", query)
+    if errors.Is(err, ErrNoTextToEdit) {
+        query.Del("text")
+        query.Add("caption", text)
+        c.limiter.wait(context.Background(), chatId, priority)
+        _, err = c.doGetRequest("editMessageCaption", query)
+    }
+    if errors.Is(err, ErrMessageNotModified) {
+        return nil
+    }
+
+    return helpers.WrapErr(err, "EditSentMessageText error")
+}
+
 func(c *Client) doGetRequest(methodName string, query url.Values) ([]byte, error) {
-    const errMsg = "get request error"
+    return c.doGetRequestWithContext(context.Background(), requestTimeout, methodName, query)
+}
+
+func(c *Client) doGetRequestWithContext(ctx context.Context, timeout time.Duration, methodName string, query url.Values) ([]byte, error) {
+    return c.doRequestWithRetry(ctx, timeout, methodName, func(ctx context.Context, requestUrl string) (*http.Request, error) {
+        request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+        if err != nil {
+            return nil, err
+        }
+        request.URL.RawQuery = query.Encode()
+        return request, nil
+    })
+}
+
+func(c *Client) doPostRequest(methodName string, data []byte) ([]byte, error) {
+    return c.doRequestWithRetry(context.Background(), requestTimeout, methodName, func(ctx context.Context, requestUrl string) (*http.Request, error) {
+        request, err := http.NewRequestWithContext(ctx, http.MethodPost, requestUrl, bytes.NewReader(data))
+        if err != nil {
+            return nil, err
+        }
+        request.Header.Set("Content-Type", "application/json")
+        return request, nil
+    })
+}
+
+// doRequestWithRetry repeats the request while telegram answers 429 and asks to wait retry_after seconds
+func(c *Client) doRequestWithRetry(
+    ctx context.Context,
+    timeout time.Duration,
+    methodName string,
+    newRequest func(ctx context.Context, requestUrl string) (*http.Request, error),
+) ([]byte, error) {
+    for attempt := 0; ; attempt++ {
+        body, err := c.doRequest(ctx, timeout, methodName, newRequest)
+        var apiErr *APIError
+        if !errors.As(err, &apiErr) || apiErr.Code != http.StatusTooManyRequests || attempt >= maxRetries {
+            return body, err
+        }
+        retryAfter := time.Duration(apiErr.Parameters.RetryAfter) * time.Second
+        if retryAfter <= 0 {
+            retryAfter = time.Second
+        }
+        log.Println("telegram rate limit on " + methodName + ", retry after " + retryAfter.String())
+        select {
+        case <-ctx.Done():
+            return nil, helpers.WrapErr(ctx.Err(), "request canceled while waiting retry_after")
+        case <-time.After(retryAfter):
+        }
+    }
+}
+
+func(c *Client) doRequest(
+    ctx context.Context,
+    timeout time.Duration,
+    methodName string,
+    newRequest func(ctx context.Context, requestUrl string) (*http.Request, error),
+) ([]byte, error) {
+    const errMsg = "request error"
+    ctx, cancel := context.WithTimeout(ctx, timeout)
+    defer cancel()
     requestUrl := url.URL{
         Scheme: "https",
         Host: c.host,
         Path: path.Join(c.botEndpoint, methodName),
     }
-    request, err := http.NewRequest(http.MethodGet, requestUrl.String(), nil)
+    request, err := newRequest(ctx, requestUrl.String())
     if err != nil {
         return nil, helpers.WrapErr(err, errMsg)
     }
 
-    request.URL.RawQuery = query.Encode()
     response, err := c.client.Do(request)
     if err != nil {
         return nil, helpers.WrapErr(err, errMsg)
@@ -279,5 +530,18 @@ func(c *Client) doGetRequest(methodName string, query url.Values) ([]byte, error
         return nil, helpers.WrapErr(err, errMsg)
     }
 
+    var result apiResponse
+    if err := json.Unmarshal(body, &result); err != nil {
+        return nil, helpers.WrapErr(err, errMsg + " cant Unmarshal response")
+    }
+    if !result.Ok {
+        return body, &APIError{
+            Method: methodName,
+            Code: result.ErrorCode,
+            Description: result.Description,
+            Parameters: result.Parameters,
+        }
+    }
+
     return body, nil
-}
\ No newline at end of file
+}
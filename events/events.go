@@ -1,10 +1,21 @@
 package events
 
+import "context"
+
 type Fetcher interface {
-    Fetch(limit int) ([]Event, error)
+    Fetch(ctx context.Context, limit int, timeout int) ([]Event, error)
     FetchDelayedRequestsToJoin(autoAccept bool) ([]Event, error)
     CheckDelayedMessageSendToAll()
+    CheckRecurringBroadcasts()
     CheckLeavers()
+    CheckExpiredCaptchas()
+    DeleteOldJoinDecisions()
+    RecoverOutbox()
+    DrainOutbox() int
+}
+
+type Receiver interface {
+    Receive(data []byte) (Event, error)
 }
 
 type Processor interface {
@@ -0,0 +1,250 @@
+package telegram
+
+import (
+    "context"
+    "log"
+    "strconv"
+    "strings"
+    "time"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/helpers"
+    "user-handler-bot/messages"
+    "user-handler-bot/storage"
+)
+
+const (
+    CampaignAudience = "/campaign-audience"
+    ToggleAudienceUsername = "/campaign-audience-username"
+    ToggleAudienceLeft = "/campaign-audience-left"
+    SetAudienceChannel = "/campaign-audience-channel"
+    SetAudienceJoined = "/campaign-audience-joined"
+    SetAudienceReceived = "/campaign-audience-received"
+    SetAudienceNotReceived = "/campaign-audience-not-received"
+    ResetAudience = "/campaign-audience-reset"
+)
+
+const (
+    campaignInputAudience = "audience"
+    campaignInputAudienceChannel = "audience-channel"
+    campaignInputAudienceJoined = "audience-joined"
+    campaignInputAudienceReceived = "audience-received"
+    campaignInputAudienceNotReceived = "audience-not-received"
+
+    AudienceDateFormat = "02.01.2006"
+)
+
+func (h* Handler) answerAudienceCallback(chatId int, messageId int, command string, campaign storage.Campaign) error {
+    switch command {
+    case CampaignAudience:
+    case ToggleAudienceUsername:
+        campaign.Audience.HasUsername = toggleAudienceFlag(campaign.Audience.HasUsername)
+    case ToggleAudienceLeft:
+        campaign.Audience.LeftChannels = toggleAudienceFlag(campaign.Audience.LeftChannels)
+    case ResetAudience:
+        campaign.Audience = storage.Audience{}
+    case SetAudienceChannel:
+        h.waitCampaignInput(campaignInputAudienceChannel, campaign.Id)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_AUDIENCE_CHANNEL, h.getBackToAudienceInlineKeyBoard(campaign.Id)),
+        )
+    case SetAudienceJoined:
+        h.waitCampaignInput(campaignInputAudienceJoined, campaign.Id)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_AUDIENCE_JOINED, h.getBackToAudienceInlineKeyBoard(campaign.Id)),
+        )
+    case SetAudienceReceived, SetAudienceNotReceived:
+        input := campaignInputAudienceReceived
+        if command == SetAudienceNotReceived {
+            input = campaignInputAudienceNotReceived
+        }
+        text, err := h.getAudienceBroadcastsText()
+        if err != nil {
+            return err
+        }
+        h.waitCampaignInput(input, campaign.Id)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, text, h.getBackToAudienceInlineKeyBoard(campaign.Id)),
+        )
+    default:
+        return h.client.SendMessage(chatId, "Command not found")
+    }
+
+    if command != CampaignAudience {
+        requireCampaignConfirmation(&campaign)
+        if err := h.storage.UpdateCampaign(context.TODO(), campaign); err != nil {
+            return helpers.WrapErr(err, "cant update campaign audience")
+        }
+    }
+    msg, err := h.makeAudienceMessage(chatId, messageId, campaign)
+    if err != nil {
+        return err
+    }
+    return h.client.UpdateInlineKeyBoard(msg)
+}
+
+// processAudienceInput handles the admin message with a value for an audience filter
+func (h* Handler) processAudienceInput(chatId int, input string, campaign storage.Campaign, text string) error {
+    text = strings.TrimSpace(text)
+    var err error
+    switch input {
+    case campaignInputAudienceChannel:
+        if _, err = strconv.Atoi(text); err == nil {
+            campaign.Audience.ChannelId = text
+        }
+    case campaignInputAudienceJoined:
+        campaign.Audience.JoinedFrom, campaign.Audience.JoinedTo, err = parseJoinedPeriod(text)
+    case campaignInputAudienceReceived:
+        campaign.Audience.ReceivedBroadcast, err = strconv.Atoi(text)
+    case campaignInputAudienceNotReceived:
+        campaign.Audience.NotReceivedBroadcast, err = strconv.Atoi(text)
+    }
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "audience input parse error"))
+        return h.client.SendInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, 0, messages.ERR_PARSE_AUDIENCE, h.getBackToAudienceInlineKeyBoard(campaign.Id)),
+        )
+    }
+
+    requireCampaignConfirmation(&campaign)
+    if err := h.storage.UpdateCampaign(context.TODO(), campaign); err != nil {
+        return helpers.WrapErr(err, "cant update campaign audience")
+    }
+    msg, err := h.makeAudienceMessage(chatId, 0, campaign)
+    if err != nil {
+        return err
+    }
+    return h.client.SendInlineKeyBoard(msg)
+}
+
+// makeAudienceMessage shows filters of the campaign with the number of users who match them now
+func (h* Handler) makeAudienceMessage(chatId int, messageId int, campaign storage.Campaign) (telegram.SendMessageRequest, error) {
+    count, err := h.storage.GetCountAudience(context.TODO(), campaign.Audience)
+    if err != nil {
+        return telegram.SendMessageRequest{}, helpers.WrapErr(err, "cant count audience")
+    }
+    text := telegram.EscapeHTML(messages.CAMPAIGN) + telegram.BoldHTML(campaign.Name) + "\n" +
+        telegram.EscapeHTML(messages.AUDIENCE + formatAudience(campaign.Audience) + "\n" + messages.RECIPIENTS + " " + strconv.Itoa(count))
+    return h.makeHTMLInlineKeyBoard(chatId, messageId, text, h.getAudienceInlineKeyBoard(campaign)), nil
+}
+
+func (h* Handler) getAudienceBroadcastsText() (string, error) {
+    startedStatuses := []string{storage.CampaignStatusSending, storage.CampaignStatusDone}
+    campaigns, err := h.storage.GetCampaigns(context.TODO(), startedStatuses, campaignsShowCount)
+    if err != nil {
+        return "", helpers.WrapErr(err, "cant get campaigns for audience")
+    }
+    text := messages.SET_AUDIENCE_BROADCAST
+    for _, campaign := range campaigns {
+        text += "\n" + strconv.Itoa(campaign.Id) + " - " + campaign.Name
+    }
+    return text, nil
+}
+
+// parseJoinedPeriod parses "from - to" dates, the last day is included
+func parseJoinedPeriod(text string) (*time.Time, *time.Time, error) {
+    parts := strings.SplitN(text, "-", 2)
+    from, err := time.ParseInLocation(AudienceDateFormat, strings.TrimSpace(parts[0]), time.Local)
+    if err != nil {
+        return nil, nil, err
+    }
+    if len(parts) == 1 {
+        return &from, nil, nil
+    }
+    to, err := time.ParseInLocation(AudienceDateFormat, strings.TrimSpace(parts[1]), time.Local)
+    if err != nil {
+        return nil, nil, err
+    }
+    to = to.AddDate(0, 0, 1)
+    return &from, &to, nil
+}
+
+// toggleAudienceFlag switches a filter between any, yes and no
+func toggleAudienceFlag(flag *bool) *bool {
+    if flag == nil {
+        value := true
+        return &value
+    }
+    if *flag {
+        value := false
+        return &value
+    }
+    return nil
+}
+
+func formatAudienceFlag(flag *bool) string {
+    if flag == nil {
+        return messages.AUDIENCE_ANY
+    }
+    if *flag {
+        return messages.AUDIENCE_YES
+    }
+    return messages.AUDIENCE_NO
+}
+
+func formatAudience(audience storage.Audience) string {
+    if audience == (storage.Audience{}) {
+        return messages.AUDIENCE_ALL_USERS
+    }
+    var filters []string
+    if audience.ChannelId != "" {
+        filters = append(filters, messages.AUDIENCE_CHANNEL + audience.ChannelId)
+    }
+    if audience.JoinedFrom != nil {
+        filters = append(filters, messages.AUDIENCE_JOINED_FROM + audience.JoinedFrom.Format(AudienceDateFormat))
+    }
+    if audience.JoinedTo != nil {
+        filters = append(filters, messages.AUDIENCE_JOINED_TO + audience.JoinedTo.AddDate(0, 0, -1).Format(AudienceDateFormat))
+    }
+    if audience.LeftChannels != nil {
+        filters = append(filters, messages.AUDIENCE_LEFT_CHANNELS + formatAudienceFlag(audience.LeftChannels))
+    }
+    if audience.ReceivedBroadcast > 0 {
+        filters = append(filters, messages.AUDIENCE_RECEIVED + strconv.Itoa(audience.ReceivedBroadcast))
+    }
+    if audience.NotReceivedBroadcast > 0 {
+        filters = append(filters, messages.AUDIENCE_NOT_RECEIVED + strconv.Itoa(audience.NotReceivedBroadcast))
+    }
+    if audience.HasUsername != nil {
+        filters = append(filters, messages.AUDIENCE_HAS_USERNAME + formatAudienceFlag(audience.HasUsername))
+    }
+    return "\n" + strings.Join(filters, "\n")
+}
+
+func (h* Handler) getAudienceInlineKeyBoard(campaign storage.Campaign) telegram.InlineKeyboardMarkup {
+    return telegram.InlineKeyboardMarkup{
+        InlineKeyboard: [][]telegram.InlineKeyboardButton{
+        {
+            {Text: messages.KEYBOARD_AUDIENCE_CHANNEL, CallbackData: makeCallback(SetAudienceChannel, campaign.Id)},
+            {Text: messages.KEYBOARD_AUDIENCE_JOINED, CallbackData: makeCallback(SetAudienceJoined, campaign.Id)},
+        },
+        {
+            {
+                Text: messages.AUDIENCE_LEFT_CHANNELS + formatAudienceFlag(campaign.Audience.LeftChannels),
+                CallbackData: makeCallback(ToggleAudienceLeft, campaign.Id),
+            },
+            {
+                Text: messages.AUDIENCE_HAS_USERNAME + formatAudienceFlag(campaign.Audience.HasUsername),
+                CallbackData: makeCallback(ToggleAudienceUsername, campaign.Id),
+            },
+        },
+        {
+            {Text: messages.KEYBOARD_AUDIENCE_RECEIVED, CallbackData: makeCallback(SetAudienceReceived, campaign.Id)},
+            {Text: messages.KEYBOARD_AUDIENCE_NOT_RECEIVED, CallbackData: makeCallback(SetAudienceNotReceived, campaign.Id)},
+        },
+        {
+            {Text: messages.KEYBOARD_AUDIENCE_RESET, CallbackData: makeCallback(ResetAudience, campaign.Id)},
+        },
+        {
+            {Text: messages.KEYBOARD_BACK_TO_CAMPAIGN, CallbackData: makeCallback(ShowCampaign, campaign.Id)},
+        },
+    },}
+}
+
+func (h* Handler) getBackToAudienceInlineKeyBoard(campaignId int) telegram.InlineKeyboardMarkup {
+    return telegram.InlineKeyboardMarkup{
+        InlineKeyboard: [][]telegram.InlineKeyboardButton{
+        {
+            {Text: messages.KEYBOARD_AUDIENCE, CallbackData: makeCallback(CampaignAudience, campaignId)},
+        },
+    },}
+}
@@ -7,7 +7,6 @@ import (
     "os"
     "strconv"
     "strings"
-    "time"
     "user-handler-bot/clients/telegram"
     "user-handler-bot/helpers"
     "user-handler-bot/messages"
@@ -15,22 +14,27 @@ import (
 )
 
 const (
-    SetSendMsg   = "/setSendingMessage"
-    ShowSendMsg = "/showSendingMessage"
     SetRequestMsg = "/setRequestMessage"
     ShowRequestMsg = "/showRequestMessage"
     RequestToJoin = "/requestToJoin"
-    SetTimeForSentMessageToAllUsers = "/setTimeForSentMessageToAllUsers"
     Statistics = "/stat"
     GetBack = "/get-back"
     InitSetDelay = "/init-set-delay"
     SetDelay = "/set-delay"
     CheckNotAcceptedUsers = "/check-not-accepted-users"
     ApproveNotAcceptedUsers = "/approve-not-accepted-users"
+    Outbox = "/outbox"
+    RetryFailedOutbox = "/retry-failed-outbox"
 )
 
 func (h* Handler) answerCallbackQuery(callback *telegram.CallbackQuery) error {
     if !h.isAdmin(callback.User.Id) {
+        if strings.HasPrefix(callback.Data, ScreeningAnswer) {
+            return h.answerScreeningButton(callback)
+        }
+        if strings.HasPrefix(callback.Data, CaptchaAnswer) {
+            return h.answerCaptcha(callback)
+        }
         return h.client.SendMessage(callback.Message.Chat.Id, messages.ACESS_DENIED)
     }
     chatId := callback.Message.Chat.Id
@@ -47,6 +51,44 @@ func (h* Handler) answerCallbackQuery(callback *telegram.CallbackQuery) error {
         )
     }
 
+    if strings.HasPrefix(command, SetRequestMessageTTL) {
+        if err := h.setRequestMessageTTL(command); err != nil {
+            return helpers.WrapErr(err, "cant setRequestMessageTTL")
+        }
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.REQUEST_MESSAGE_TTL, h.getRequestMessageTTLInlineKeyBoard()),
+        )
+    }
+
+    if strings.HasPrefix(command, SetCaptchaDeadline) {
+        if err := h.setCaptchaDeadline(command); err != nil {
+            return helpers.WrapErr(err, "cant setCaptchaDeadline")
+        }
+        return h.sendCaptchaSettings(chatId, messageId)
+    }
+
+    if strings.HasPrefix(command, ReviewRequests) {
+        return h.answerReviewCallback(chatId, messageId, command, callback.User)
+    }
+    if action, requestId, ok := parseCallback(command); ok && strings.HasPrefix(action, RequestCard) {
+        return h.answerRequestCard(chatId, messageId, action, requestId, callback.User)
+    }
+    if action, param, ok := parseCallback(command); ok && strings.HasPrefix(action, JoinRule) {
+        return h.answerJoinRuleCallback(chatId, messageId, action, param)
+    }
+    if strings.HasPrefix(command, UseTemplateForCampaign) {
+        return h.useTemplateForCampaign(chatId, messageId, command)
+    }
+    if action, templateId, ok := parseCallback(command); ok && strings.HasPrefix(action, ShowTemplate) {
+        return h.answerTemplateCallback(chatId, messageId, action, templateId)
+    }
+    if action, campaignId, ok := parseCallback(command); ok && strings.HasPrefix(action, ShowCampaign) {
+        return h.answerCampaignCallback(chatId, messageId, action, campaignId)
+    }
+    if action, recurringId, ok := parseCallback(command); ok && strings.HasPrefix(action, ShowRecurringBroadcast) {
+        return h.answerRecurringBroadcastCallback(chatId, messageId, action, recurringId)
+    }
+
     switch command {
     case CheckNotAcceptedUsers:
         statusAcceptedWas := false
@@ -64,37 +106,24 @@ func (h* Handler) answerCallbackQuery(callback *telegram.CallbackQuery) error {
         return h.client.UpdateInlineKeyBoard(
             h.makeInlineKeyBoard(chatId, messageId, messages.START_ACCEPT_USERS, h.getBaseInlineKeyBoard()),
         )
-    case SetTimeForSentMessageToAllUsers:
-        h.setNewTimeForSentMessageToAll = true
+    case InitSetRequestMessageTTL:
         return h.client.UpdateInlineKeyBoard(
-            h.makeInlineKeyBoard(chatId, messageId, messages.SET_TIME_FOR_SENDING_MESSAGE, h.getBackToStartInlineKeyBoard()),
+            h.makeInlineKeyBoard(chatId, messageId, messages.REQUEST_MESSAGE_TTL, h.getRequestMessageTTLInlineKeyBoard()),
         )
     case InitSetDelay:
         return h.client.SendInlineKeyBoard(
             h.makeInlineKeyBoard(chatId, messageId, messages.KEYBOARD_ACCEPTANCE_DELAY, h.getDelayRequestToJoinInlineKeyBoard()),
         )
     case GetBack:
-        h.setNewTimeForSentMessageToAll = false
+        h.waitCampaignInput("", 0)
         return h.client.SendInlineKeyBoard(
             h.makeInlineKeyBoard(chatId, messageId, messages.LIST_OF_COMMANDS, h.getBaseInlineKeyBoard()),
         )
-    case SetSendMsg:
-        h.nextSetSendMsg = storage.KeyAllMessage
-        return h.client.UpdateInlineKeyBoard(
-            h.makeInlineKeyBoard(chatId, messageId, messages.SET_SENDING_MESSAGE, h.getBaseInlineKeyBoard()),
-        )
     case SetRequestMsg:
+        h.waitCampaignInput("", 0)
         h.nextSetSendMsg = storage.KeyRequestMessage
         return h.client.UpdateInlineKeyBoard(
-            h.makeInlineKeyBoard(chatId, messageId, messages.SET_REQUEST_TO_JOIN_MESSAGE, h.getBaseInlineKeyBoard()),
-        )
-    case ShowSendMsg:
-        err := h.showCurrentMessage(chatId, storage.KeyAllMessage)
-        if err != nil {
-            return err
-        }
-        return h.client.SendInlineKeyBoard(
-            h.makeInlineKeyBoard(chatId, messageId, messages.KEYBOARD_THIS_IS_MSG_TO_SEND, h.getBackToStartInlineKeyBoard()),
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_REQUEST_TO_JOIN_MESSAGE + "\n\n" + messages.PLACEHOLDERS_HINT, h.getRequestMessageTemplatesInlineKeyBoard()),
         )
     case ShowRequestMsg:
         err := h.showCurrentMessage(chatId, storage.KeyRequestMessage)
@@ -106,6 +135,46 @@ func (h* Handler) answerCallbackQuery(callback *telegram.CallbackQuery) error {
         )
     case Statistics:
         return h.sendStat(chatId, messageId)
+    case Campaigns:
+        return h.sendCampaigns(chatId, messageId, messages.CAMPAIGNS_LIST)
+    case Templates:
+        return h.sendTemplates(chatId, messageId, messages.TEMPLATES_LIST)
+    case NewTemplate:
+        h.waitTemplateInput(templateInputName, 0)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_TEMPLATE_NAME, h.getBackToStartInlineKeyBoard()),
+        )
+    case RecurringBroadcasts:
+        return h.sendRecurringBroadcasts(chatId, messageId, messages.RECURRING_BROADCASTS_LIST)
+    case NewCampaign:
+        h.waitCampaignInput(campaignInputName, 0)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_CAMPAIGN_NAME, h.getCampaignsInlineKeyBoard()),
+        )
+    case ToggleRequestCards:
+        if err := h.toggleRequestCards(); err != nil {
+            return helpers.WrapErr(err, "cant toggle request cards")
+        }
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.LIST_OF_COMMANDS, h.getBaseInlineKeyBoard()),
+        )
+    case JoinRules:
+        h.waitRuleInput("")
+        return h.sendJoinRules(chatId, messageId, "")
+    case JoinDecisions:
+        return h.sendJoinDecisions(chatId, messageId)
+    case CaptchaSettings:
+        return h.sendCaptchaSettings(chatId, messageId)
+    case Outbox:
+        return h.sendOutboxStat(chatId, messageId)
+    case RetryFailedOutbox:
+        count, err := h.storage.RetryFailedOutbox(context.TODO())
+        if err != nil {
+            return helpers.WrapErr(err, "cant RetryFailedOutbox")
+        }
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.OUTBOX_RETRIED + strconv.Itoa(count), h.getOutboxInlineKeyBoard()),
+        )
     case RequestToJoin:
         if h.autoAcceptRequestEnable {
             h.autoAcceptRequestEnable = false
@@ -142,11 +211,20 @@ func (h* Handler) makeInlineKeyBoard(chatId int, messageId int, text string, key
         Text:        text,
         ReplyMarkup: &keyBoard,
         MessageId: messageId,
+        LinkPreviewOptions: &telegram.LinkPreviewOptions{IsDisabled: true},
     }
 
     return msg
 }
 
+// makeHTMLInlineKeyBoard makes a menu message with HTML text, every part of the text which is not markup,
+// messages too, must be escaped with telegram.EscapeHTML
+func (h* Handler) makeHTMLInlineKeyBoard(chatId int, messageId int, text string, keyBoard telegram.InlineKeyboardMarkup) telegram.SendMessageRequest {
+    msg := h.makeInlineKeyBoard(chatId, messageId, text, keyBoard)
+    msg.ParseMode = telegram.ParseModeHTML
+    return msg
+}
+
 func (h* Handler) setAutoAcceptRequestStatusToFile() {
     file, err := os.Create(checkAutoAcceptRequestEnableFileStatus)
     if err != nil {
@@ -177,71 +255,19 @@ func (h* Handler) isAdmin(userId int) bool {
     return found
 }
 
-func (h* Handler) setMsg(msgId int, chatId int, key string) error {
-    return h.storage.SaveMessage(context.TODO(), msgId, chatId, key)
+func (h* Handler) setMsg(message *telegram.Message, key string) error {
+    h.rememberMediaGroup(message, mediaGroup{key: key})
+    return h.storage.SaveMessage(context.TODO(), message.Id, message.Chat.Id, captureContent(message), key)
 }
 
 func(h* Handler) showCurrentMessage(chatId int, key string) error {
-    currentMessageId, err := h.storage.GetCurrentMessage(context.TODO(), key)
+    currentMessage, err := h.storage.GetCurrentMessage(context.TODO(), key)
     if err != nil {
         log.Println(err)
         return h.client.SendMessage(chatId, "message not found")
     }
-    return h.client.ForwardMessage(chatId, currentMessageId.FromChatId, currentMessageId.MessageId)
-}
-
-func (h* Handler) sendMessageForAllUsers(chatId int, messageId int) error {
-    users, err := h.storage.GetAllUsers(context.TODO())
-    if err != nil {
-        return helpers.WrapErr(err, "cant get users for send message")
-    }
-    h.processSendMessageForAllUsers(users)
-    return nil
-}
-
-func (h* Handler) processSendMessageForAllUsers(users []storage.User) {
-    message, err := h.storage.GetCurrentMessage(context.TODO(), storage.KeyAllMessage)
-    if err != nil {
-        log.Println(helpers.WrapErr(err, "Cant get message for processSendMessageForAllUsers"))
-    }
-    var usersIds []int
-    for i, user := range users {
-        if len(user.ChannelsIds) > 0 {
-            err := h.UpdateUsersActiveChannels(user)
-            if err != nil {
-                log.Println(helpers.WrapErr(err, "cant updateUsersActiveChannels in processSendMessageForAllUsers"))
-            }
-        }
-
-        if user.LastMessageId == message.MessageId {
-            continue
-        }
-
-        // telegram limits
-        if i % 20 == 0 && i != 0 {
-            time.Sleep(3 * time.Second)
-        }
-        err := h.client.ForwardMessage(user.Id, message.FromChatId, message.MessageId)
-        if err != nil {
-            log.Println(err)
-            log.Println(helpers.WrapErr(
-                err, "cant send message for username:" + user.Username + 
-                " user_first_name:" + user.FirstName + 
-                " user_last_name:" + user.LastName +
-                " user_id:" + strconv.Itoa(user.Id)))
-        }
-        users[i].LastMessageId = message.MessageId
-        usersIds = append(usersIds, user.Id)
-    }
-    err = h.storage.UpdateUsersLastMessage(context.TODO(), strconv.Itoa(message.MessageId), usersIds)
-    if err != nil {
-        log.Println(helpers.WrapErr(err, "cant UpdateUsersLastMessage in processSendMessageForAllUsers"))
-    }
-
-    err = h.storage.DeleteMessage(context.TODO(), storage.KeyAllMessage)
-    if err != nil {
-        log.Println(helpers.WrapErr(err, "cant DeleteMessage after sent message to all users"))
-    }
+    _, err = h.sendStoredMessage(chatId, currentMessage, telegram.PriorityAdmin)
+    return err
 }
 
 func (h* Handler) UpdateUsersActiveChannels(user storage.User) error {
@@ -265,48 +291,49 @@ func (h* Handler) UpdateUsersActiveChannels(user storage.User) error {
 }
 
 func (h* Handler) sendStat(chatId int, messageId int) error {
-    lastMessage, err := h.storage.GetCurrentMessage(context.TODO(), storage.KeyLastMessageAll)
-    if err != nil {
-        if lastMessage.MessageId == 0 {
-            return h.client.UpdateInlineKeyBoard(
-                h.makeInlineKeyBoard(
-                    chatId,
-                    messageId,
-                    messages.ERR_MSG_TO_ALL_NOT_FOUND,
-                    h.getBaseInlineKeyBoard(),
-                ),
-            )
-        } else {
-            return err
-        }
-    }
-
-    usersCount, err := h.storage.GetCountUsers(context.TODO())
+    startedStatuses := []string{storage.CampaignStatusSending, storage.CampaignStatusPaused, storage.CampaignStatusDone}
+    campaigns, err := h.storage.GetCampaigns(context.TODO(), startedStatuses, 1)
     if err != nil {
         return err
     }
-    usersCountWithLastMsg, err := h.storage.GetCountUsersWithLastMsgId(context.TODO(), lastMessage.MessageId)
-    if err != nil {
-        return err
-    }
-
-    process := messages.USERS_NOT_FOUND
-    if usersCount > 0 {
-        process = messages.USERS_IN_DB + " " + strconv.Itoa(usersCount) + ". " + 
-            messages.SENT + " " + strconv.Itoa((usersCountWithLastMsg * 100) / usersCount) + "%. "
+    if len(campaigns) == 0 {
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.NOT_ACTIVE_SEND_MESSAGE_FOR_ALL, h.getBaseInlineKeyBoard()),
+        )
     }
+    return h.sendCampaignStat(chatId, messageId, campaigns[0])
+}
 
-    msgWasSent := messages.SEND_MESSAGE_WILL_BE_SENT + lastMessage.TimeToSent.Format(LastMessageForAllFormat)
-    isLastMessageWasSent := time.Now().After(lastMessage.TimeToSent)
-    if !isLastMessageWasSent {
-        msgWasSent = messages.MESSAGE_WAS_SENT + ": " + lastMessage.TimeToSent.Format(LastMessageForAllFormat)
+func (h* Handler) sendOutboxStat(chatId int, messageId int) error {
+    stats, err := h.storage.GetOutboxStats(context.TODO())
+    if err != nil {
+        return helpers.WrapErr(err, "cant GetOutboxStats")
     }
-    if lastMessage.TimeToSent.Unix() == 0 {
-        msgWasSent = messages.TIME_FOR_SENDING_NOT_FOUND
+    failed, err := h.storage.GetFailedOutbox(context.TODO(), outboxShowFailedCount)
+    if err != nil {
+        return helpers.WrapErr(err, "cant GetFailedOutbox")
+    }
+
+    text := telegram.BoldHTML(messages.OUTBOX_STATUS)
+    for _, status := range []string{
+        storage.OutboxStatusPending,
+        storage.OutboxStatusSending,
+        storage.OutboxStatusSent,
+        storage.OutboxStatusFailed,
+        storage.OutboxStatusPaused,
+        storage.OutboxStatusCancelled,
+    } {
+        text += "\n" + status + ": " + strconv.Itoa(stats[status])
+    }
+    if len(failed) > 0 {
+        text += "\n\n" + telegram.BoldHTML(messages.OUTBOX_LAST_ERRORS)
+        for _, item := range failed {
+            text += "\n" + strconv.Itoa(item.ChatId) + " (" + item.Source + "): " + telegram.EscapeHTML(item.LastError)
+        }
     }
 
     return h.client.UpdateInlineKeyBoard(
-        h.makeInlineKeyBoard(chatId, messageId, process + msgWasSent, h.getBaseInlineKeyBoard()),
+        h.makeHTMLInlineKeyBoard(chatId, messageId, text, h.getOutboxInlineKeyBoard()),
     )
 }
 
@@ -336,11 +363,18 @@ func (h* Handler) getBaseInlineKeyBoard() telegram.InlineKeyboardMarkup {
     } else {
         statusRequestToJoin = messages.KEYBOARD_OFF_REQUEST_TO_JOIN
     }
+    statusRequestCards := messages.KEYBOARD_OFF_REQUEST_CARDS
+    if h.isRequestCardsEnabled() {
+        statusRequestCards = messages.KEYBOARD_ON_REQUEST_CARDS
+    }
     return telegram.InlineKeyboardMarkup{
         InlineKeyboard: [][]telegram.InlineKeyboardButton{
         {
-            {Text: messages.KEYBOARD_SET_MESSAGE_TO_SEND, CallbackData: SetSendMsg},
-            {Text: messages.KEYBOARD_SHOW_MESSAGE_TO_SEND, CallbackData: ShowSendMsg},
+            {Text: messages.KEYBOARD_CAMPAIGNS, CallbackData: Campaigns},
+            {Text: messages.KEYBOARD_RECURRING_BROADCASTS, CallbackData: RecurringBroadcasts},
+        },
+        {
+            {Text: messages.KEYBOARD_TEMPLATES, CallbackData: Templates},
         },
         {  
             {Text: messages.KEYBOARD_SET_REQUEST_MSG, CallbackData: SetRequestMsg},
@@ -349,11 +383,20 @@ func (h* Handler) getBaseInlineKeyBoard() telegram.InlineKeyboardMarkup {
         {
             {Text: statusRequestToJoin, CallbackData: RequestToJoin},
         },
+        {
+            {Text: statusRequestCards, CallbackData: ToggleRequestCards},
+        },
         {
             {Text: messages.KEYBOARD_ACCEPTANCE_DELAY, CallbackData: InitSetDelay},
         },
         {
-            {Text: messages.KEYBOARD_SET_TIME_FOR_SEND_MESSAGE_FOR_ALL_USERS, CallbackData: SetTimeForSentMessageToAllUsers},
+            {Text: messages.KEYBOARD_REQUEST_MESSAGE_TTL, CallbackData: InitSetRequestMessageTTL},
+        },
+        {
+            {Text: messages.KEYBOARD_CAPTCHA, CallbackData: CaptchaSettings},
+        },
+        {
+            {Text: messages.KEYBOARD_JOIN_RULES, CallbackData: JoinRules},
         },
         {
             {Text: messages.KEYBOARD_STATISTIC, CallbackData: Statistics},
@@ -361,6 +404,24 @@ func (h* Handler) getBaseInlineKeyBoard() telegram.InlineKeyboardMarkup {
         {
             {Text: messages.KEYBOARD_CHECK_NOT_ACCEPTED_USERS, CallbackData: CheckNotAcceptedUsers},
         },
+        {
+            {Text: messages.KEYBOARD_REVIEW_REQUESTS, CallbackData: ReviewRequests},
+        },
+        {
+            {Text: messages.KEYBOARD_OUTBOX, CallbackData: Outbox},
+        },
+    },}
+}
+
+func (h* Handler) getOutboxInlineKeyBoard() telegram.InlineKeyboardMarkup {
+    return telegram.InlineKeyboardMarkup{
+        InlineKeyboard: [][]telegram.InlineKeyboardButton{
+        {
+            {Text: messages.KEYBOARD_RETRY_FAILED_OUTBOX, CallbackData: RetryFailedOutbox},
+        },
+        {
+            {Text: messages.KEYBOARD_GET_BACK, CallbackData: GetBack},
+        },
     },}
 }
 
@@ -389,6 +450,7 @@ func (h* Handler) getNotAcceptedUsersInlineKeyBoard() telegram.InlineKeyboardMar
 func (h* Handler) getButtonsNotAcceptedUsers() [][]telegram.InlineKeyboardButton {
     var result [][]telegram.InlineKeyboardButton
     result = append(result, []telegram.InlineKeyboardButton{{Text: messages.APPROVE_NOT_ACCEPTED_USERS, CallbackData: ApproveNotAcceptedUsers}})
+    result = append(result, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_REVIEW_REQUESTS, CallbackData: ReviewRequests}})
     result = append(result, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_GET_BACK, CallbackData: GetBack}})
     return result
 }
@@ -406,15 +468,7 @@ func (h* Handler) getButtonsDelayRequestToJoin(delay int) [][]telegram.InlineKey
         }
         buttons = append(buttons, telegram.InlineKeyboardButton{Text: text, CallbackData: callback})
     }
-    var result [][]telegram.InlineKeyboardButton
-    chunkSize := 4
-    for i := 0; i < len(buttons); i += chunkSize {
-        end := i + chunkSize
-        if end > len(buttons) {
-            end = len(buttons)
-        }
-        result = append(result, buttons[i:end])
-    }
+    result := chunkButtons(buttons, 4)
     result = append(result, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_GET_BACK, CallbackData: GetBack}})
     return result
 }
@@ -0,0 +1,673 @@
+package telegram
+
+import (
+    "context"
+    "log"
+    "strconv"
+    "strings"
+    "time"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/helpers"
+    "user-handler-bot/messages"
+    "user-handler-bot/storage"
+)
+
+const (
+    Campaigns = "/campaigns"
+    NewCampaign = "/new-campaign"
+    ShowCampaign = "/campaign"
+    SetCampaignMessage = "/campaign-set-message"
+    ShowCampaignMessage = "/campaign-show-message"
+    SetCampaignTime = "/campaign-set-time"
+    SetCampaignSchedule = "/campaign-set-schedule"
+    RenameCampaign = "/campaign-rename"
+    ConfirmCampaign = "/campaign-confirm"
+    TestSendCampaign = "/campaign-test-send"
+    PauseCampaign = "/campaign-pause"
+    ResumeCampaign = "/campaign-resume"
+    CancelCampaign = "/campaign-cancel"
+    DeleteCampaign = "/campaign-delete"
+    CampaignStatistics = "/campaign-stat"
+    CampaignNotDelivered = "/campaign-not-delivered"
+)
+
+const (
+    campaignInputName = "name"
+    campaignInputMessage = "message"
+    campaignInputTime = "time"
+    campaignInputSchedule = "schedule"
+
+    campaignsShowCount = 10
+)
+
+// CheckDelayedMessageSendToAll starts campaigns whose time has come and continues campaigns interrupted by a restart
+func(h* Handler) CheckDelayedMessageSendToAll() {
+    campaigns, err := h.storage.GetDueCampaigns(context.TODO())
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "Cant get campaigns for CheckDelayedMessageSendToAll"))
+        return
+    }
+    for _, campaign := range campaigns {
+        if err := h.sendCampaign(campaign); err != nil {
+            log.Println(helpers.WrapErr(err, "sendCampaign from CheckDelayedMessageSendToAll campaign: " + campaign.Name))
+        }
+    }
+}
+
+func (h* Handler) sendCampaign(campaign storage.Campaign) error {
+    if campaign.Status == storage.CampaignStatusScheduled {
+        scheduled := []string{storage.CampaignStatusScheduled}
+        started, err := h.storage.SwitchCampaignStatus(context.TODO(), campaign.Id, scheduled, storage.CampaignStatusSending)
+        if err != nil || !started {
+            return err
+        }
+    }
+
+    // a restarted bot continues after the last enqueued batch
+    lastUserId, finished, err := h.storage.GetBroadcastCheckpoint(context.TODO(), campaign.Id)
+    if err != nil {
+        return helpers.WrapErr(err, "cant get broadcast checkpoint")
+    }
+    for !finished {
+        users, err := h.storage.GetUsersAfter(context.TODO(), campaign.Audience, lastUserId, broadcastBatchSize)
+        if err != nil {
+            return helpers.WrapErr(err, "cant get users for send message")
+        }
+        if len(users) == 0 {
+            if err := h.storage.FinishBroadcastCheckpoint(context.TODO(), campaign.Id); err != nil {
+                return err
+            }
+            break
+        }
+        lastUserId = users[len(users) - 1].Id
+        enqueued, err := h.storage.EnqueueBroadcastBatch(context.TODO(), campaign.Id, h.makeBroadcastItems(users, campaign), lastUserId)
+        if err != nil {
+            return helpers.WrapErr(err, "cant enqueue broadcast batch")
+        }
+        if !enqueued {
+            // the campaign was paused or cancelled by an admin
+            return nil
+        }
+        h.reportCampaignProgress(campaign.Id, false)
+    }
+
+    // the campaign is done when the outbox worker has sent all its messages
+    outboxStats, err := h.storage.GetOutboxSourceStats(context.TODO(), storage.OutboxSourceBroadcast, campaign.Id)
+    if err != nil {
+        return err
+    }
+    if outboxStats[storage.OutboxStatusPending] + outboxStats[storage.OutboxStatusSending] > 0 {
+        h.reportCampaignProgress(campaign.Id, false)
+        return nil
+    }
+    sending := []string{storage.CampaignStatusSending}
+    done, err := h.storage.SwitchCampaignStatus(context.TODO(), campaign.Id, sending, storage.CampaignStatusDone)
+    if done {
+        h.reportCampaignProgress(campaign.Id, true)
+    }
+    return err
+}
+
+// makeBroadcastItems prepares outbox items, the outbox worker sends them and marks last_message_sent for users
+func (h* Handler) makeBroadcastItems(users []storage.User, campaign storage.Campaign) []storage.OutboxItem {
+    message := campaignMessage(campaign)
+    var items []storage.OutboxItem
+    for _, user := range users {
+        if len(user.ChannelsIds) > 0 {
+            err := h.UpdateUsersActiveChannels(user)
+            if err != nil {
+                log.Println(helpers.WrapErr(err, "cant updateUsersActiveChannels in makeBroadcastItems"))
+            }
+        }
+
+        item, err := newMessageItem(user.Id, message, campaign.TTL, telegram.PriorityBulk, storage.OutboxSourceBroadcast, campaign.Id)
+        if err != nil {
+            log.Println(helpers.WrapErr(
+                err, "cant make message for username:" + user.Username +
+                " user_first_name:" + user.FirstName +
+                " user_last_name:" + user.LastName +
+                " user_id:" + strconv.Itoa(user.Id)))
+            continue
+        }
+        items = append(items, item)
+    }
+    return items
+}
+
+func (h* Handler) answerCampaignCallback(chatId int, messageId int, command string, campaignId int) error {
+    campaign, err := h.storage.GetCampaign(context.TODO(), campaignId)
+    if err != nil {
+        log.Println(err)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.CAMPAIGN_NOT_FOUND, h.getCampaignsInlineKeyBoard()),
+        )
+    }
+
+    switch command {
+    case ShowCampaign:
+        return h.client.UpdateInlineKeyBoard(
+            h.makeHTMLInlineKeyBoard(chatId, messageId, formatCampaign(campaign), h.getCampaignInlineKeyBoard(campaign)),
+        )
+    case ShowCampaignMessage:
+        _, err := h.sendStoredMessage(chatId, campaignMessage(campaign), telegram.PriorityAdmin)
+        if err != nil {
+            return err
+        }
+        return h.client.SendInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.KEYBOARD_THIS_IS_MSG_TO_SEND, h.getBackToCampaignInlineKeyBoard(campaign.Id)),
+        )
+    case CampaignStatistics:
+        return h.sendCampaignStat(chatId, messageId, campaign)
+    case CampaignNotDelivered:
+        return h.sendNotDeliveredUsers(chatId, messageId, campaign)
+    case SetCampaignSchedule:
+        // any campaign with a message can be repeated, every run is a new campaign
+        h.waitCampaignInput(campaignInputSchedule, campaign.Id)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_SCHEDULE, h.getBackToCampaignInlineKeyBoard(campaign.Id)),
+        )
+    case PauseCampaign, ResumeCampaign, CancelCampaign:
+        return h.controlCampaign(chatId, messageId, command, campaign)
+    case RecallCampaign:
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.CONFIRM_RECALL_CAMPAIGN, h.getRecallCampaignInlineKeyBoard(campaign.Id)),
+        )
+    case ConfirmRecallCampaign:
+        return h.changeCampaignEverywhere(chatId, messageId, campaign, outboxKindDelete, "")
+    case EditCampaignText:
+        h.waitCampaignInput(campaignInputEditText, campaign.Id)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_CAMPAIGN_NEW_TEXT, h.getBackToCampaignInlineKeyBoard(campaign.Id)),
+        )
+    }
+
+    if !isCampaignEditable(campaign) && command != DeleteCampaign {
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.CAMPAIGN_CANT_BE_CHANGED, h.getCampaignInlineKeyBoard(campaign)),
+        )
+    }
+
+    if strings.HasPrefix(command, CampaignAudience) {
+        return h.answerAudienceCallback(chatId, messageId, command, campaign)
+    }
+
+    switch command {
+    case TestSendCampaign:
+        if campaign.MessageId <= 0 {
+            return h.client.UpdateInlineKeyBoard(
+                h.makeInlineKeyBoard(chatId, messageId, messages.ERR_MSG_TO_ALL_NOT_FOUND, h.getCampaignInlineKeyBoard(campaign)),
+            )
+        }
+        return h.sendCampaignPreview(campaign)
+    case ConfirmCampaign:
+        if campaign.Status != storage.CampaignStatusAwaitingConfirmation {
+            return h.client.UpdateInlineKeyBoard(
+                h.makeInlineKeyBoard(chatId, messageId, messages.CAMPAIGN_CANT_BE_CHANGED, h.getCampaignInlineKeyBoard(campaign)),
+            )
+        }
+        awaiting := []string{storage.CampaignStatusAwaitingConfirmation}
+        _, err := h.storage.SwitchCampaignStatus(context.TODO(), campaign.Id, awaiting, storage.CampaignStatusScheduled)
+        if err != nil {
+            return err
+        }
+        // the admin who confirmed the campaign sees the progress of sending
+        if err := h.storage.SetCampaignProgressMessage(context.TODO(), campaign.Id, chatId, 0); err != nil {
+            return err
+        }
+        campaign.Status = storage.CampaignStatusScheduled
+        return h.client.UpdateInlineKeyBoard(
+            h.makeHTMLInlineKeyBoard(chatId, messageId, formatCampaign(campaign), h.getCampaignInlineKeyBoard(campaign)),
+        )
+    case SetCampaignMessage:
+        h.waitCampaignInput(campaignInputMessage, campaign.Id)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_SENDING_MESSAGE + "\n\n" + messages.PLACEHOLDERS_HINT, h.getCampaignTemplatesInlineKeyBoard(campaign.Id)),
+        )
+    case SetCampaignTime:
+        h.waitCampaignInput(campaignInputTime, campaign.Id)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_TIME_FOR_SENDING_MESSAGE, h.getBackToCampaignInlineKeyBoard(campaign.Id)),
+        )
+    case SetCampaignTTL:
+        h.waitCampaignInput(campaignInputTTL, campaign.Id)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_CAMPAIGN_TTL, h.getBackToCampaignInlineKeyBoard(campaign.Id)),
+        )
+    case RenameCampaign:
+        h.waitCampaignInput(campaignInputName, campaign.Id)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_CAMPAIGN_NAME, h.getBackToCampaignInlineKeyBoard(campaign.Id)),
+        )
+    case DeleteCampaign:
+        if isCampaignRunning(campaign) {
+            return h.client.UpdateInlineKeyBoard(
+                h.makeInlineKeyBoard(chatId, messageId, messages.CAMPAIGN_CANT_BE_CHANGED, h.getCampaignInlineKeyBoard(campaign)),
+            )
+        }
+        if err := h.storage.DeleteCampaign(context.TODO(), campaign.Id); err != nil {
+            return err
+        }
+        if err := h.storage.DeleteBroadcastCheckpoint(context.TODO(), campaign.Id); err != nil {
+            log.Println(err)
+        }
+        return h.sendCampaigns(chatId, messageId, messages.CAMPAIGN_DELETED)
+    default:
+        return h.client.SendMessage(chatId, "Command not found")
+    }
+}
+
+// controlCampaign pauses, resumes or cancels the campaign, the outbox worker does not send messages of paused
+// and cancelled campaigns and the state stays in the database after a restart
+func (h* Handler) controlCampaign(chatId int, messageId int, command string, campaign storage.Campaign) error {
+    var switched bool
+    var err error
+    switch command {
+    case PauseCampaign:
+        switched, err = h.storage.PauseCampaign(context.TODO(), campaign.Id)
+    case ResumeCampaign:
+        switched, err = h.storage.ResumeCampaign(context.TODO(), campaign.Id)
+    case CancelCampaign:
+        switched, err = h.storage.CancelCampaign(context.TODO(), campaign.Id)
+    }
+    if err != nil {
+        return err
+    }
+    if switched {
+        h.reportCampaignProgress(campaign.Id, true)
+    }
+
+    campaign, err = h.storage.GetCampaign(context.TODO(), campaign.Id)
+    if err != nil {
+        return err
+    }
+    if switched && messageId == campaign.ProgressMessageId {
+        // the button was pressed on the progress message which is already updated
+        return nil
+    }
+    text := formatCampaign(campaign)
+    if !switched {
+        text = telegram.EscapeHTML(messages.CAMPAIGN_CANT_BE_CHANGED) + "\n\n" + text
+    }
+    return h.client.UpdateInlineKeyBoard(
+        h.makeHTMLInlineKeyBoard(chatId, messageId, text, h.getCampaignInlineKeyBoard(campaign)),
+    )
+}
+
+func (h* Handler) waitCampaignInput(input string, campaignId int) {
+    h.campaignInput = input
+    h.campaignInputId = campaignId
+    h.templateInput = ""
+    h.ruleInput = ""
+}
+
+// processCampaignInput handles the admin message after he pressed a campaign button which asks for input
+func (h* Handler) processCampaignInput(message *telegram.Message) error {
+    chatId := message.Chat.Id
+    input := h.campaignInput
+    campaignId := h.campaignInputId
+    h.waitCampaignInput("", 0)
+
+    if input == campaignInputName && campaignId == 0 {
+        id, err := h.storage.CreateCampaign(context.TODO(), storage.Campaign{
+            Name: strings.TrimSpace(message.Text),
+            Status: storage.CampaignStatusDraft,
+            CreatedBy: message.From.Id,
+        })
+        if err != nil {
+            return helpers.WrapErr(err, "cant create campaign")
+        }
+        campaignId = id
+    }
+
+    campaign, err := h.storage.GetCampaign(context.TODO(), campaignId)
+    if err != nil {
+        return helpers.WrapErr(err, "cant get campaign for input")
+    }
+    if input == campaignInputSchedule {
+        return h.addRecurringBroadcast(chatId, campaign, message.Text)
+    }
+    if input == campaignInputEditText {
+        return h.changeCampaignEverywhere(chatId, 0, campaign, outboxKindEdit, message.Text)
+    }
+    if input == campaignInputTTL {
+        return h.processTTLInput(chatId, campaign, message.Text)
+    }
+    if strings.HasPrefix(input, campaignInputAudience) {
+        return h.processAudienceInput(chatId, input, campaign, message.Text)
+    }
+
+    switch input {
+    case campaignInputName:
+        campaign.Name = strings.TrimSpace(message.Text)
+    case campaignInputMessage:
+        campaign.FromChatId = chatId
+        campaign.MessageId = message.Id
+        campaign.Content = captureContent(message)
+        h.rememberMediaGroup(message, mediaGroup{campaignId: campaign.Id})
+        requireCampaignConfirmation(&campaign)
+    case campaignInputTime:
+        timeToRun, err := time.ParseInLocation(LastMessageForAllFormat, strings.TrimSpace(message.Text), time.Local)
+        if err != nil {
+            log.Println(helpers.WrapErr(err, "campaign time parse error"))
+            return h.client.SendInlineKeyBoard(
+                h.makeInlineKeyBoard(chatId, 0, messages.ERR_PARSE_TIME_FOR_SENT_MSG_TO_ALL, h.getCampaignInlineKeyBoard(campaign)),
+            )
+        }
+        if campaign.MessageId <= 0 {
+            return h.client.SendInlineKeyBoard(
+                h.makeInlineKeyBoard(chatId, 0, messages.ERR_MSG_TO_ALL_NOT_FOUND, h.getCampaignInlineKeyBoard(campaign)),
+            )
+        }
+        campaign.TimeToSent = timeToRun
+        campaign.Status = storage.CampaignStatusAwaitingConfirmation
+    }
+
+    if err := h.storage.UpdateCampaign(context.TODO(), campaign); err != nil {
+        return helpers.WrapErr(err, "cant update campaign from input")
+    }
+    if campaign.Status == storage.CampaignStatusAwaitingConfirmation {
+        return h.sendCampaignPreview(campaign)
+    }
+    return h.client.SendInlineKeyBoard(
+        h.makeHTMLInlineKeyBoard(chatId, 0, formatCampaign(campaign), h.getCampaignInlineKeyBoard(campaign)),
+    )
+}
+
+// sendCampaignPreview copies the campaign message to all admins with the number of recipients and the time,
+// any of them can confirm the campaign from the preview
+func (h* Handler) sendCampaignPreview(campaign storage.Campaign) error {
+    count, err := h.storage.GetCountAudience(context.TODO(), campaign.Audience)
+    if err != nil {
+        return helpers.WrapErr(err, "cant count audience for preview")
+    }
+    text := messages.CAMPAIGN_TEST
+    if campaign.Status == storage.CampaignStatusAwaitingConfirmation {
+        text = messages.CAMPAIGN_PREVIEW
+    }
+    text = telegram.EscapeHTML(text) + "\n\n" + formatCampaign(campaign) + "\n" + telegram.EscapeHTML(messages.RECIPIENTS) + " " + strconv.Itoa(count)
+    for _, adminId := range h.client.AdminsId {
+        _, err := h.sendStoredMessage(adminId, campaignMessage(campaign), telegram.PriorityAdmin)
+        if err != nil {
+            log.Println(helpers.WrapErr(err, "cant send campaign preview to admin " + strconv.Itoa(adminId)))
+            continue
+        }
+        _, err = h.client.SendMessageWithKeyBoard(h.makeHTMLInlineKeyBoard(adminId, 0, text, h.getCampaignInlineKeyBoard(campaign)))
+        if err != nil {
+            log.Println(helpers.WrapErr(err, "cant send campaign preview keyboard to admin " + strconv.Itoa(adminId)))
+        }
+    }
+    return nil
+}
+
+// requireCampaignConfirmation sends a changed scheduled campaign back to confirmation
+func requireCampaignConfirmation(campaign *storage.Campaign) {
+    if campaign.Status == storage.CampaignStatusScheduled {
+        campaign.Status = storage.CampaignStatusAwaitingConfirmation
+    }
+}
+
+func (h* Handler) sendCampaigns(chatId int, messageId int, text string) error {
+    campaigns, err := h.storage.GetCampaigns(context.TODO(), nil, campaignsShowCount)
+    if err != nil {
+        return helpers.WrapErr(err, "cant get campaigns")
+    }
+    var buttons [][]telegram.InlineKeyboardButton
+    for _, campaign := range campaigns {
+        buttons = append(buttons, []telegram.InlineKeyboardButton{{
+            Text: campaign.Name + " [" + campaign.Status + "]",
+            CallbackData: makeCallback(ShowCampaign, campaign.Id),
+        }})
+    }
+    buttons = append(buttons, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_NEW_CAMPAIGN, CallbackData: NewCampaign}})
+    buttons = append(buttons, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_GET_BACK, CallbackData: GetBack}})
+
+    return h.client.UpdateInlineKeyBoard(
+        h.makeInlineKeyBoard(chatId, messageId, text, telegram.InlineKeyboardMarkup{InlineKeyboard: buttons}),
+    )
+}
+
+func (h* Handler) sendCampaignStat(chatId int, messageId int, campaign storage.Campaign) error {
+    usersCount, err := h.storage.GetCountAudience(context.TODO(), campaign.Audience)
+    if err != nil {
+        return err
+    }
+    outboxStats, err := h.storage.GetOutboxSourceStats(context.TODO(), storage.OutboxSourceBroadcast, campaign.Id)
+    if err != nil {
+        return err
+    }
+    deliveryStats, err := h.storage.GetDeliveryStats(context.TODO(), campaign.Id)
+    if err != nil {
+        return err
+    }
+
+    process := messages.USERS_NOT_FOUND
+    if usersCount > 0 {
+        process = messages.RECIPIENTS + " " + strconv.Itoa(usersCount) + ". " +
+            messages.SENT + " " + strconv.Itoa((deliveryStats[storage.DeliveryStatusSent] * 100) / usersCount) + "%"
+    }
+    queued := outboxStats[storage.OutboxStatusPending] + outboxStats[storage.OutboxStatusSending]
+    process += "\n" + messages.QUEUED + strconv.Itoa(queued)
+    if outboxStats[storage.OutboxStatusPaused] > 0 {
+        process += "\n" + messages.PAUSED_IN_QUEUE + strconv.Itoa(outboxStats[storage.OutboxStatusPaused])
+    }
+    if outboxStats[storage.OutboxStatusCancelled] > 0 {
+        process += "\n" + messages.CANCELLED_NOT_SENT + strconv.Itoa(outboxStats[storage.OutboxStatusCancelled])
+    }
+    changes, err := h.formatCampaignChanges(campaign.Id)
+    if err != nil {
+        return err
+    }
+    text := formatCampaign(campaign) + "\n\n" + telegram.EscapeHTML(process + changes) + "\n\n" + formatDeliveryStats(deliveryStats)
+
+    return h.client.UpdateInlineKeyBoard(
+        h.makeHTMLInlineKeyBoard(chatId, messageId, text, h.getCampaignStatisticsInlineKeyBoard(campaign.Id)),
+    )
+}
+
+// sendNotDeliveredUsers shows who did not get the campaign and why
+func (h* Handler) sendNotDeliveredUsers(chatId int, messageId int, campaign storage.Campaign) error {
+    notDeliveredStatuses := []string{
+        storage.DeliveryStatusBlocked,
+        storage.DeliveryStatusChatNotFound,
+        storage.DeliveryStatusRateLimited,
+        storage.DeliveryStatusFailed,
+    }
+    deliveries, err := h.storage.GetDeliveries(context.TODO(), campaign.Id, notDeliveredStatuses, notDeliveredShowCount)
+    if err != nil {
+        return err
+    }
+
+    text := telegram.BoldHTML(messages.NOT_DELIVERED_USERS)
+    for _, delivery := range deliveries {
+        user, err := h.storage.GetUser(context.TODO(), delivery.UserId)
+        if err != nil {
+            log.Println(err)
+        }
+        text += "\n" + strconv.Itoa(delivery.UserId) + " " + telegram.EscapeHTML(user.Username + " " + user.FirstName + " " + user.LastName) +
+            " - " + delivery.Status + " " + delivery.Timestamp.Format(LastMessageForAllFormat)
+    }
+
+    return h.client.UpdateInlineKeyBoard(
+        h.makeHTMLInlineKeyBoard(chatId, messageId, text, h.getCampaignStatisticsInlineKeyBoard(campaign.Id)),
+    )
+}
+
+// formatCampaign shows the campaign as HTML
+func formatCampaign(campaign storage.Campaign) string {
+    text := messages.CAMPAIGN_STATUS + campaign.Status + "\n"
+    if campaign.TimeToSent.Unix() > 0 {
+        text += messages.SEND_MESSAGE_WILL_BE_SENT + campaign.TimeToSent.Format(LastMessageForAllFormat)
+    } else {
+        text += messages.TIME_FOR_SENDING_NOT_FOUND
+    }
+    text += "\n" + messages.AUDIENCE + formatAudience(campaign.Audience)
+    text += "\n" + messages.CAMPAIGN_TTL + formatTTL(campaign.TTL)
+    if campaign.MessageId <= 0 {
+        text += "\n" + messages.ERR_MSG_TO_ALL_NOT_FOUND
+    }
+    return telegram.EscapeHTML(messages.CAMPAIGN) + telegram.BoldHTML(campaign.Name) + "\n" + telegram.EscapeHTML(text)
+}
+
+func formatDeliveryStats(stats map[string]int) string {
+    text := telegram.BoldHTML(messages.DELIVERIES_BY_STATUS)
+    for _, status := range []string{
+        storage.DeliveryStatusSent,
+        storage.DeliveryStatusBlocked,
+        storage.DeliveryStatusChatNotFound,
+        storage.DeliveryStatusRateLimited,
+        storage.DeliveryStatusFailed,
+        storage.DeliveryStatusDeleted,
+    } {
+        text += "\n" + status + ": " + strconv.Itoa(stats[status])
+    }
+    return text
+}
+
+// isCampaignRunning tells whether the campaign has started and was not finished or cancelled
+func isCampaignRunning(campaign storage.Campaign) bool {
+    return campaign.Status == storage.CampaignStatusSending || campaign.Status == storage.CampaignStatusPaused
+}
+
+func isCampaignEditable(campaign storage.Campaign) bool {
+    return campaign.Status == storage.CampaignStatusDraft ||
+        campaign.Status == storage.CampaignStatusAwaitingConfirmation ||
+        campaign.Status == storage.CampaignStatusScheduled
+}
+
+func (h* Handler) getCampaignInlineKeyBoard(campaign storage.Campaign) telegram.InlineKeyboardMarkup {
+    var buttons [][]telegram.InlineKeyboardButton
+    if campaign.Status == storage.CampaignStatusAwaitingConfirmation {
+        buttons = append(buttons, []telegram.InlineKeyboardButton{
+            {Text: messages.KEYBOARD_CONFIRM_CAMPAIGN, CallbackData: makeCallback(ConfirmCampaign, campaign.Id)},
+        })
+    }
+    if isCampaignEditable(campaign) {
+        buttons = append(buttons,
+            []telegram.InlineKeyboardButton{
+                {Text: messages.KEYBOARD_SET_MESSAGE_TO_SEND, CallbackData: makeCallback(SetCampaignMessage, campaign.Id)},
+                {Text: messages.KEYBOARD_SHOW_MESSAGE_TO_SEND, CallbackData: makeCallback(ShowCampaignMessage, campaign.Id)},
+            },
+            []telegram.InlineKeyboardButton{
+                {Text: messages.KEYBOARD_SET_TIME_FOR_SEND_MESSAGE_FOR_ALL_USERS, CallbackData: makeCallback(SetCampaignTime, campaign.Id)},
+            },
+            []telegram.InlineKeyboardButton{
+                {Text: messages.KEYBOARD_TEST_SEND_CAMPAIGN, CallbackData: makeCallback(TestSendCampaign, campaign.Id)},
+            },
+            []telegram.InlineKeyboardButton{
+                {Text: messages.KEYBOARD_AUDIENCE, CallbackData: makeCallback(CampaignAudience, campaign.Id)},
+                {Text: messages.KEYBOARD_CAMPAIGN_TTL, CallbackData: makeCallback(SetCampaignTTL, campaign.Id)},
+            },
+            []telegram.InlineKeyboardButton{
+                {Text: messages.KEYBOARD_RENAME_CAMPAIGN, CallbackData: makeCallback(RenameCampaign, campaign.Id)},
+                {Text: messages.KEYBOARD_CANCEL_CAMPAIGN, CallbackData: makeCallback(CancelCampaign, campaign.Id)},
+            },
+        )
+    } else {
+        buttons = append(buttons, []telegram.InlineKeyboardButton{
+            {Text: messages.KEYBOARD_SHOW_MESSAGE_TO_SEND, CallbackData: makeCallback(ShowCampaignMessage, campaign.Id)},
+        })
+    }
+    if campaign.Status == storage.CampaignStatusSending {
+        buttons = append(buttons, []telegram.InlineKeyboardButton{
+            {Text: messages.KEYBOARD_PAUSE, CallbackData: makeCallback(PauseCampaign, campaign.Id)},
+            {Text: messages.KEYBOARD_CANCEL_CAMPAIGN, CallbackData: makeCallback(CancelCampaign, campaign.Id)},
+        })
+    }
+    if campaign.Status == storage.CampaignStatusPaused {
+        buttons = append(buttons, []telegram.InlineKeyboardButton{
+            {Text: messages.KEYBOARD_RESUME, CallbackData: makeCallback(ResumeCampaign, campaign.Id)},
+            {Text: messages.KEYBOARD_CANCEL_CAMPAIGN, CallbackData: makeCallback(CancelCampaign, campaign.Id)},
+        })
+    }
+    if isCampaignFinished(campaign) {
+        buttons = append(buttons, []telegram.InlineKeyboardButton{
+            {Text: messages.KEYBOARD_RECALL_CAMPAIGN, CallbackData: makeCallback(RecallCampaign, campaign.Id)},
+            {Text: messages.KEYBOARD_EDIT_CAMPAIGN_TEXT, CallbackData: makeCallback(EditCampaignText, campaign.Id)},
+        })
+    }
+    buttons = append(buttons, []telegram.InlineKeyboardButton{
+        {Text: messages.KEYBOARD_REPEAT_CAMPAIGN, CallbackData: makeCallback(SetCampaignSchedule, campaign.Id)},
+    })
+    buttons = append(buttons, []telegram.InlineKeyboardButton{
+        {Text: messages.KEYBOARD_STATISTIC, CallbackData: makeCallback(CampaignStatistics, campaign.Id)},
+    })
+    if !isCampaignRunning(campaign) {
+        buttons = append(buttons, []telegram.InlineKeyboardButton{
+            {Text: messages.KEYBOARD_DELETE_CAMPAIGN, CallbackData: makeCallback(DeleteCampaign, campaign.Id)},
+        })
+    }
+    buttons = append(buttons, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_BACK_TO_CAMPAIGNS, CallbackData: Campaigns}})
+    return telegram.InlineKeyboardMarkup{InlineKeyboard: buttons}
+}
+
+func (h* Handler) getCampaignStatisticsInlineKeyBoard(campaignId int) telegram.InlineKeyboardMarkup {
+    return telegram.InlineKeyboardMarkup{
+        InlineKeyboard: [][]telegram.InlineKeyboardButton{
+        {
+            {Text: messages.KEYBOARD_NOT_DELIVERED_USERS, CallbackData: makeCallback(CampaignNotDelivered, campaignId)},
+        },
+        {
+            {Text: messages.KEYBOARD_BACK_TO_CAMPAIGN, CallbackData: makeCallback(ShowCampaign, campaignId)},
+        },
+    },}
+}
+
+func (h* Handler) getBackToCampaignInlineKeyBoard(campaignId int) telegram.InlineKeyboardMarkup {
+    return telegram.InlineKeyboardMarkup{
+        InlineKeyboard: [][]telegram.InlineKeyboardButton{
+        {
+            {Text: messages.KEYBOARD_BACK_TO_CAMPAIGN, CallbackData: makeCallback(ShowCampaign, campaignId)},
+        },
+    },}
+}
+
+func (h* Handler) getCampaignsInlineKeyBoard() telegram.InlineKeyboardMarkup {
+    return telegram.InlineKeyboardMarkup{
+        InlineKeyboard: [][]telegram.InlineKeyboardButton{
+        {
+            {Text: messages.KEYBOARD_BACK_TO_CAMPAIGNS, CallbackData: Campaigns},
+        },
+    },}
+}
+
+// makeCallback adds a parameter to the command in the same way as SetDelay does
+func makeCallback(command string, param int) string {
+    return command + "?" + strconv.Itoa(param)
+}
+
+// makePairCallback adds two parameters to the command, parsePairCallback reads them
+func makePairCallback(command string, first int, second int) string {
+    return makeCallback(command, first) + "&" + strconv.Itoa(second)
+}
+
+func parsePairCallback(command string) (string, int, int, bool) {
+    action, params, found := strings.Cut(command, "?")
+    if !found {
+        return command, 0, 0, false
+    }
+    first, second, found := strings.Cut(params, "&")
+    if !found {
+        return command, 0, 0, false
+    }
+    firstParam, err := strconv.Atoi(first)
+    if err != nil {
+        return command, 0, 0, false
+    }
+    secondParam, err := strconv.Atoi(second)
+    if err != nil {
+        return command, 0, 0, false
+    }
+    return action, firstParam, secondParam, true
+}
+
+func parseCallback(command string) (string, int, bool) {
+    parts := strings.SplitN(command, "?", 2)
+    if len(parts) != 2 {
+        return command, 0, false
+    }
+    param, err := strconv.Atoi(parts[1])
+    if err != nil {
+        return command, 0, false
+    }
+    return parts[0], param, true
+}
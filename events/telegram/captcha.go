@@ -0,0 +1,267 @@
+package telegram
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "log"
+    "math/rand"
+    "strconv"
+    "time"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/events"
+    "user-handler-bot/helpers"
+    "user-handler-bot/messages"
+    "user-handler-bot/storage"
+)
+
+const (
+    CaptchaSettings = "/captcha"
+    SetCaptchaDeadline = "/captcha-set-deadline"
+    // CaptchaAnswer has two parameters: the captcha and the chosen button
+    CaptchaAnswer = "/captcha-answer"
+)
+
+const captchaOptionsCount = 4
+
+// captchaRetryDelay is how long a decision of the finished captcha may be in flight before it is sent again
+const captchaRetryDelay = time.Minute
+
+// captchaEmojis are pictures for the captcha, the user gets the name and looks for the picture
+var captchaEmojis = []struct {
+    emoji string
+    name  string
+}{
+    {"🍎", "apple"},
+    {"🚗", "car"},
+    {"🐱", "cat"},
+    {"🐶", "dog"},
+    {"🏠", "house"},
+    {"🌙", "moon"},
+    {"🍕", "pizza"},
+    {"⚽", "ball"},
+    {"🎸", "guitar"},
+    {"✈️", "plane"},
+}
+
+func (h* Handler) getCaptchaDeadline() time.Duration {
+    seconds, _ := h.storage.GetDelays(context.TODO(), storage.KeyCaptchaDeadline)
+    return time.Duration(seconds) * time.Second
+}
+
+// startCaptcha sends the user a challenge instead of approving the request at once
+func (h* Handler) startCaptcha(event events.Event, deadline time.Duration) error {
+    request := event.Meta.(*telegram.ChatJoinRequest)
+    eventData, err := json.Marshal(event)
+    if err != nil {
+        return helpers.WrapErr(err, "startCaptcha: cant marshal event")
+    }
+    userChatId := request.UserChatId
+    if userChatId == 0 {
+        userChatId = request.User.Id
+    }
+    question, options, answer := newCaptchaChallenge()
+    captcha := storage.Captcha{
+        UserId: request.User.Id,
+        ChatId: request.Chat.Id,
+        UserChatId: userChatId,
+        Event: string(eventData),
+        Answer: answer,
+        Deadline: time.Now().Add(deadline),
+    }
+    captchaId, err := h.storage.CreateCaptcha(context.TODO(), captcha)
+    if err != nil {
+        return err
+    }
+
+    var buttons []telegram.InlineKeyboardButton
+    for i, option := range options {
+        buttons = append(buttons, telegram.InlineKeyboardButton{Text: option, CallbackData: makePairCallback(CaptchaAnswer, captchaId, i)})
+    }
+    text := telegram.EscapeHTML(messages.CAPTCHA_CHALLENGE + formatTTL(deadline)) + "\n\n" + telegram.BoldHTML(question)
+    messageId, err := h.client.SendMessageWithKeyBoard(
+        h.makeHTMLInlineKeyBoard(userChatId, 0, text, telegram.InlineKeyboardMarkup{InlineKeyboard: chunkButtons(buttons, 2)}),
+    )
+    if err != nil {
+        // the user can not get the captcha, the deadline declines the request
+        log.Println(helpers.WrapErr(err, "cant send captcha userId: " + strconv.Itoa(request.User.Id)))
+        return nil
+    }
+    return h.storage.SetCaptchaMessage(context.TODO(), captchaId, messageId)
+}
+
+// newCaptchaChallenge returns the question, buttons and the index of the right button
+func newCaptchaChallenge() (string, []string, int) {
+    var question string
+    var options []string
+    if rand.Intn(2) == 0 {
+        a, b := rand.Intn(9) + 1, rand.Intn(9) + 1
+        question = messages.CAPTCHA_ARITHMETIC + strconv.Itoa(a) + " + " + strconv.Itoa(b) + " = ?"
+        // wrong sums are near the right one so they can not be told apart by size
+        for _, delta := range rand.Perm(6)[:captchaOptionsCount] {
+            options = append(options, strconv.Itoa(a + b + delta - 3))
+        }
+        if !containsString(options, strconv.Itoa(a + b)) {
+            options[0] = strconv.Itoa(a + b)
+        }
+        rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+        for i, option := range options {
+            if option == strconv.Itoa(a + b) {
+                return question, options, i
+            }
+        }
+    }
+    picked := rand.Perm(len(captchaEmojis))[:captchaOptionsCount]
+    answer := rand.Intn(captchaOptionsCount)
+    for _, index := range picked {
+        options = append(options, captchaEmojis[index].emoji)
+    }
+    question = messages.CAPTCHA_EMOJI + captchaEmojis[picked[answer]].name
+    return question, options, answer
+}
+
+// answerCaptcha approves the request for the right button and declines it for a wrong one
+func (h* Handler) answerCaptcha(callback *telegram.CallbackQuery) error {
+    _, captchaId, option, ok := parsePairCallback(callback.Data)
+    if !ok {
+        return nil
+    }
+    captcha, err := h.storage.GetCaptcha(context.TODO(), captchaId)
+    if err != nil {
+        return err
+    }
+    if captcha.UserId != callback.User.Id {
+        return nil
+    }
+    status := storage.CaptchaStatusFailed
+    if option == captcha.Answer {
+        status = storage.CaptchaStatusPassed
+    }
+    return h.finishCaptcha(captcha, status)
+}
+
+// CheckExpiredCaptchas declines requests of users who did not solve the captcha in time
+// and sends again decisions which telegram did not get
+func (h* Handler) CheckExpiredCaptchas() {
+    captchas, err := h.storage.GetExpiredCaptchas(context.TODO())
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "Cant get captchas for CheckExpiredCaptchas"))
+        return
+    }
+    for _, captcha := range captchas {
+        if err := h.finishCaptcha(captcha, storage.CaptchaStatusExpired); err != nil {
+            log.Println(helpers.WrapErr(err, "finishCaptcha from CheckExpiredCaptchas id: " + strconv.Itoa(captcha.Id)))
+        }
+    }
+
+    // the delay lets decisions which are being sent now finish first
+    captchas, err = h.storage.GetUndecidedCaptchas(context.TODO(), time.Now().Add(-captchaRetryDelay))
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "Cant get undecided captchas for CheckExpiredCaptchas"))
+        return
+    }
+    for _, captcha := range captchas {
+        if err := h.decideCaptcha(captcha); err != nil {
+            log.Println(helpers.WrapErr(err, "decideCaptcha from CheckExpiredCaptchas id: " + strconv.Itoa(captcha.Id)))
+        }
+    }
+}
+
+func (h* Handler) finishCaptcha(captcha storage.Captcha, status string) error {
+    finished, err := h.storage.FinishCaptcha(context.TODO(), captcha.Id, status)
+    if err != nil || !finished {
+        return err
+    }
+    captcha.Status = status
+    text := messages.CAPTCHA_FAILED
+    if status == storage.CaptchaStatusExpired {
+        text = messages.CAPTCHA_EXPIRED
+    }
+    if status == storage.CaptchaStatusPassed {
+        text = messages.CAPTCHA_PASSED
+    }
+    if captcha.MessageId > 0 {
+        noButtons := telegram.InlineKeyboardMarkup{InlineKeyboard: [][]telegram.InlineKeyboardButton{}}
+        err := h.client.EditMessageWithKeyBoard(h.makeInlineKeyBoard(captcha.UserChatId, captcha.MessageId, text, noButtons))
+        if err != nil {
+            log.Println(helpers.WrapErr(err, "cant update captcha message"))
+        }
+    }
+    return h.decideCaptcha(captcha)
+}
+
+// decideCaptcha approves or declines the request of the finished captcha, the decision stays pending
+// when telegram does not get it and CheckExpiredCaptchas sends it again
+func (h* Handler) decideCaptcha(captcha storage.Captcha) error {
+    if captcha.Status == storage.CaptchaStatusPassed {
+        event, err := parseRequestToJoinEvent(captcha.Event)
+        if err != nil {
+            return err
+        }
+        if err := h.approveRequestToJoin(event, "captcha: " + captcha.Status); err != nil {
+            return err
+        }
+        return h.storage.SetCaptchaDecided(context.TODO(), captcha.Id)
+    }
+
+    ok, err := h.client.DeclineChatJoinRequest(captcha.UserId, captcha.ChatId)
+    if err != nil && !errors.Is(err, telegram.ErrJoinRequestMissing) {
+        return helpers.WrapErr(err, "cant decline request to join after captcha userId: " + strconv.Itoa(captcha.UserId))
+    }
+    if err := h.storage.SetCaptchaDecided(context.TODO(), captcha.Id); err != nil {
+        return err
+    }
+    if !ok {
+        return nil
+    }
+    // the event is only needed for the log, the request is already declined
+    event, err := parseRequestToJoinEvent(captcha.Event)
+    if err != nil {
+        return helpers.WrapErr(err, "cant log declined captcha id: " + strconv.Itoa(captcha.Id))
+    }
+    return h.logJoinDecision(event.Meta.(*telegram.ChatJoinRequest), storage.JoinDecisionDeclined, "captcha: " + captcha.Status)
+}
+
+func (h* Handler) sendCaptchaSettings(chatId int, messageId int) error {
+    stats, err := h.storage.GetCaptchaStats(context.TODO())
+    if err != nil {
+        return err
+    }
+    text := telegram.EscapeHTML(messages.CAPTCHA_DEADLINE + formatTTL(h.getCaptchaDeadline())) + "\n\n" + telegram.BoldHTML(messages.CAPTCHA_STATS)
+    for _, status := range []string{
+        storage.CaptchaStatusWaiting,
+        storage.CaptchaStatusPassed,
+        storage.CaptchaStatusFailed,
+        storage.CaptchaStatusExpired,
+    } {
+        text += "\n" + status + ": " + strconv.Itoa(stats[status])
+    }
+    return h.client.UpdateInlineKeyBoard(
+        h.makeHTMLInlineKeyBoard(chatId, messageId, text, h.getCaptchaInlineKeyBoard()),
+    )
+}
+
+func (h* Handler) setCaptchaDeadline(command string) error {
+    _, seconds, ok := parseCallback(command)
+    if !ok {
+        return errors.New("cant parse captcha deadline from " + command)
+    }
+    return h.storage.UpdateDelays(context.TODO(), storage.KeyCaptchaDeadline, seconds)
+}
+
+func (h* Handler) getCaptchaInlineKeyBoard() telegram.InlineKeyboardMarkup {
+    current := int(h.getCaptchaDeadline() / time.Second)
+    var buttons []telegram.InlineKeyboardButton
+    // telegram lets the bot write to the user only for 5 minutes after the request
+    for _, minutes := range []int{0, 1, 2, 3, 5} {
+        seconds := minutes * int(time.Minute / time.Second)
+        text := formatTTL(time.Duration(seconds) * time.Second)
+        if seconds == current {
+            text = text + "*"
+        }
+        buttons = append(buttons, telegram.InlineKeyboardButton{Text: text, CallbackData: makeCallback(SetCaptchaDeadline, seconds)})
+    }
+    result := chunkButtons(buttons, 5)
+    result = append(result, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_GET_BACK, CallbackData: GetBack}})
+    return telegram.InlineKeyboardMarkup{InlineKeyboard: result}
+}
@@ -0,0 +1,106 @@
+package telegram
+
+import (
+    "context"
+    "log"
+    "strconv"
+    "strings"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/helpers"
+    "user-handler-bot/messages"
+    "user-handler-bot/storage"
+)
+
+const (
+    ToggleRequestCards = "/toggle-request-cards"
+    // RequestCard is the prefix of buttons on cards, they have id of the request
+    RequestCard = "/request-card"
+    ApproveRequestCard = "/request-card-approve"
+    DeclineRequestCard = "/request-card-decline"
+)
+
+func (h* Handler) isRequestCardsEnabled() bool {
+    enabled, _ := h.storage.GetDelays(context.TODO(), storage.KeyNotifyRequestToJoin)
+    return enabled > 0
+}
+
+func (h* Handler) toggleRequestCards() error {
+    enabled := 1
+    if h.isRequestCardsEnabled() {
+        enabled = 0
+    }
+    return h.storage.UpdateDelays(context.TODO(), storage.KeyNotifyRequestToJoin, enabled)
+}
+
+// sendRequestCards sends every admin a card of the request to join with buttons to decide it, the text is HTML
+func (h* Handler) sendRequestCards(requestId int, text string) error {
+    for _, adminId := range h.client.AdminsId {
+        messageId, err := h.client.SendMessageWithKeyBoard(
+            h.makeHTMLInlineKeyBoard(adminId, 0, text, h.getRequestCardInlineKeyBoard(requestId)),
+        )
+        if err != nil {
+            log.Println(helpers.WrapErr(err, "cant send request card to admin " + strconv.Itoa(adminId)))
+            continue
+        }
+        err = h.storage.SaveRequestCard(context.TODO(), storage.RequestCard{RequestId: requestId, ChatId: adminId, MessageId: messageId})
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (h* Handler) answerRequestCard(chatId int, messageId int, action string, requestId int, admin telegram.User) error {
+    if action != ApproveRequestCard && action != DeclineRequestCard {
+        return h.client.SendMessage(chatId, "Command not found")
+    }
+    text, err := h.decideRequestToJoin(admin, action == ApproveRequestCard, requestId)
+    if err != nil {
+        return err
+    }
+    // decided requests get all their cards edited, the text is only needed when the request was decided before
+    if text == messages.REQUEST_ALREADY_HANDLED {
+        return h.client.SendMessage(chatId, text)
+    }
+    return nil
+}
+
+// updateRequestCards replaces cards of the decided request in all admin chats with the decision, the text is HTML
+func (h* Handler) updateRequestCards(requestId int, text string) {
+    cards, err := h.storage.GetRequestCards(context.TODO(), requestId)
+    if err != nil {
+        log.Println(err)
+        return
+    }
+    for _, card := range cards {
+        noButtons := telegram.InlineKeyboardMarkup{InlineKeyboard: [][]telegram.InlineKeyboardButton{}}
+        err := h.client.EditMessageWithKeyBoard(h.makeHTMLInlineKeyBoard(card.ChatId, card.MessageId, text, noButtons))
+        if err != nil {
+            log.Println(helpers.WrapErr(err, "cant update request card in chat " + strconv.Itoa(card.ChatId)))
+        }
+    }
+    if err := h.storage.DeleteRequestCards(context.TODO(), requestId); err != nil {
+        log.Println(err)
+    }
+}
+
+func formatAdminName(admin telegram.User) string {
+    name := strings.TrimSpace(admin.FirstName + " " + admin.LastName)
+    if name == "" && admin.Username != "" {
+        return "@" + admin.Username
+    }
+    if name == "" {
+        return strconv.Itoa(admin.Id)
+    }
+    return name
+}
+
+func (h* Handler) getRequestCardInlineKeyBoard(requestId int) telegram.InlineKeyboardMarkup {
+    return telegram.InlineKeyboardMarkup{
+        InlineKeyboard: [][]telegram.InlineKeyboardButton{
+        {
+            {Text: messages.KEYBOARD_APPROVE_REQUEST, CallbackData: makeCallback(ApproveRequestCard, requestId)},
+            {Text: messages.KEYBOARD_DECLINE_REQUEST, CallbackData: makeCallback(DeclineRequestCard, requestId)},
+        },
+    },}
+}
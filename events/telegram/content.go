@@ -0,0 +1,131 @@
+package telegram
+
+import (
+    "context"
+    "encoding/json"
+    "log"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+// mediaGroup remembers where the first message of an album was saved,
+// telegram sends other messages of the album as separate updates
+type mediaGroup struct {
+    id         string
+    key        string
+    campaignId int
+    templateId int
+}
+
+// captureContent keeps the content of the admin message, so it can be sent after the admin deletes the message.
+// An empty string means the message is not supported and can only be copied from the admin chat
+func captureContent(message *telegram.Message) string {
+    content, err := telegram.NewMessageContent(*message)
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "message will be copied from the admin chat"))
+        return ""
+    }
+    data, err := json.Marshal(content)
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "cant marshal message content"))
+        return ""
+    }
+    return string(data)
+}
+
+// sendStoredMessage sends the captured content, a message without it is copied from the admin chat.
+// It returns ids of all sent messages, an album has several of them
+func (h* Handler) sendStoredMessage(chatId int, message storage.ForwardMessage, priority telegram.Priority) ([]int, error) {
+    if message.Content == "" {
+        messageId, err := h.client.ForwardMessage(chatId, message.FromChatId, message.MessageId, priority)
+        if err != nil {
+            return nil, err
+        }
+        return []int{messageId}, nil
+    }
+    var content telegram.MessageContent
+    if err := json.Unmarshal([]byte(message.Content), &content); err != nil {
+        return nil, helpers.WrapErr(err, "cant unmarshal message content")
+    }
+    return h.sendContent(chatId, content, priority)
+}
+
+// sendContent renders placeholders for the recipient and sends the content
+func (h* Handler) sendContent(chatId int, content telegram.MessageContent, priority telegram.Priority) ([]int, error) {
+    return h.client.SendContent(chatId, h.renderContent(chatId, content), priority)
+}
+
+func campaignMessage(campaign storage.Campaign) storage.ForwardMessage {
+    return storage.ForwardMessage{
+        FromChatId: campaign.FromChatId,
+        MessageId: campaign.MessageId,
+        Content: campaign.Content,
+    }
+}
+
+// rememberMediaGroup keeps where the message was saved, group tells one of the key, the campaign or the template
+func (h* Handler) rememberMediaGroup(message *telegram.Message, group mediaGroup) {
+    group.id = message.MediaGroupId
+    h.lastMediaGroup = group
+}
+
+// addToMediaGroup appends the next message of the album to the content saved from its first message,
+// it returns false if the message is not a part of the last album
+func (h* Handler) addToMediaGroup(message *telegram.Message) (bool, error) {
+    group := h.lastMediaGroup
+    if message.MediaGroupId == "" || message.MediaGroupId != group.id {
+        return false, nil
+    }
+    item, err := telegram.NewMessageContent(*message)
+    if err != nil {
+        return true, helpers.WrapErr(err, "cant add message to media group")
+    }
+
+    if group.key != "" {
+        stored, err := h.storage.GetCurrentMessage(context.TODO(), group.key)
+        if err != nil {
+            return true, err
+        }
+        content, err := appendMedia(stored.Content, item)
+        if err != nil {
+            return true, err
+        }
+        return true, h.storage.SaveMessage(context.TODO(), stored.MessageId, stored.FromChatId, content, group.key)
+    }
+
+    if group.templateId > 0 {
+        template, err := h.storage.GetTemplate(context.TODO(), group.templateId)
+        if err != nil {
+            return true, err
+        }
+        if template.Content, err = appendMedia(template.Content, item); err != nil {
+            return true, err
+        }
+        return true, h.storage.UpdateTemplate(context.TODO(), template)
+    }
+
+    campaign, err := h.storage.GetCampaign(context.TODO(), group.campaignId)
+    if err != nil {
+        return true, err
+    }
+    if campaign.Content, err = appendMedia(campaign.Content, item); err != nil {
+        return true, err
+    }
+    return true, h.storage.UpdateCampaign(context.TODO(), campaign)
+}
+
+func appendMedia(stored string, item telegram.MessageContent) (string, error) {
+    var content telegram.MessageContent
+    if stored != "" {
+        if err := json.Unmarshal([]byte(stored), &content); err != nil {
+            return "", helpers.WrapErr(err, "cant unmarshal media group content")
+        }
+    }
+    content.Media = append(content.Media, item.Media...)
+    data, err := json.Marshal(content)
+    if err != nil {
+        return "", helpers.WrapErr(err, "cant marshal media group content")
+    }
+    return string(data), nil
+}
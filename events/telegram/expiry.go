@@ -0,0 +1,112 @@
+package telegram
+
+import (
+    "context"
+    "errors"
+    "log"
+    "strconv"
+    "strings"
+    "time"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/helpers"
+    "user-handler-bot/messages"
+    "user-handler-bot/storage"
+)
+
+const (
+    SetCampaignTTL = "/campaign-set-ttl"
+    InitSetRequestMessageTTL = "/init-set-request-ttl"
+    SetRequestMessageTTL = "/set-request-ttl"
+)
+
+const (
+    campaignInputTTL = "ttl"
+
+    // telegram does not allow to delete messages older than 48 hours
+    maxMessageTTL = 48 * time.Hour
+)
+
+var errInvalidTTL = errors.New("ttl must be from 0 to 48h")
+
+func (h* Handler) processTTLInput(chatId int, campaign storage.Campaign, text string) error {
+    ttl, err := parseTTL(text)
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "campaign ttl parse error"))
+        return h.client.SendInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, 0, messages.ERR_PARSE_TTL, h.getBackToCampaignInlineKeyBoard(campaign.Id)),
+        )
+    }
+    campaign.TTL = ttl
+    requireCampaignConfirmation(&campaign)
+    if err := h.storage.UpdateCampaign(context.TODO(), campaign); err != nil {
+        return helpers.WrapErr(err, "cant update campaign ttl")
+    }
+    return h.client.SendInlineKeyBoard(
+        h.makeHTMLInlineKeyBoard(chatId, 0, formatCampaign(campaign), h.getCampaignInlineKeyBoard(campaign)),
+    )
+}
+
+// parseTTL reads durations like 90m or 24h, 0 turns deleting off
+func parseTTL(text string) (time.Duration, error) {
+    ttl, err := time.ParseDuration(strings.TrimSpace(text))
+    if err != nil {
+        return 0, err
+    }
+    if ttl < 0 || ttl > maxMessageTTL {
+        return 0, errInvalidTTL
+    }
+    return ttl, nil
+}
+
+func formatTTL(ttl time.Duration) string {
+    if ttl <= 0 {
+        return messages.TTL_OFF
+    }
+    text := ""
+    if hours := int(ttl / time.Hour); hours > 0 {
+        text += strconv.Itoa(hours) + "h"
+    }
+    if minutes := int(ttl % time.Hour / time.Minute); minutes > 0 {
+        text += strconv.Itoa(minutes) + "min"
+    }
+    if text == "" {
+        text = strconv.Itoa(int(ttl.Seconds())) + "sec"
+    }
+    return text
+}
+
+func (h* Handler) setRequestMessageTTL(command string) error {
+    _, seconds, ok := parseCallback(command)
+    if !ok {
+        return errors.New("cant parse ttl from " + command)
+    }
+    return h.storage.UpdateDelays(context.TODO(), storage.KeyRequestMessageTTL, seconds)
+}
+
+func (h* Handler) getRequestMessageTTLInlineKeyBoard() telegram.InlineKeyboardMarkup {
+    current, _ := h.storage.GetDelays(context.TODO(), storage.KeyRequestMessageTTL)
+    var buttons []telegram.InlineKeyboardButton
+    for _, hours := range []int{0, 1, 3, 6, 12, 24, 36, 48} {
+        seconds := hours * int(time.Hour / time.Second)
+        text := formatTTL(time.Duration(seconds) * time.Second)
+        if seconds == current {
+            text = text + "*"
+        }
+        buttons = append(buttons, telegram.InlineKeyboardButton{Text: text, CallbackData: makeCallback(SetRequestMessageTTL, seconds)})
+    }
+    result := chunkButtons(buttons, 4)
+    result = append(result, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_GET_BACK, CallbackData: GetBack}})
+    return telegram.InlineKeyboardMarkup{InlineKeyboard: result}
+}
+
+func chunkButtons(buttons []telegram.InlineKeyboardButton, size int) [][]telegram.InlineKeyboardButton {
+    var result [][]telegram.InlineKeyboardButton
+    for i := 0; i < len(buttons); i += size {
+        end := i + size
+        if end > len(buttons) {
+            end = len(buttons)
+        }
+        result = append(result, buttons[i:end])
+    }
+    return result
+}
@@ -0,0 +1,307 @@
+package telegram
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "log"
+    "strconv"
+    "sync"
+    "time"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+const (
+    outboxBatchSize   = 30
+    outboxWorkers     = 10
+    outboxMaxAttempts = 5
+    outboxRetryDelay  = 30 * time.Second
+    outboxShowFailedCount = 5
+    notDeliveredShowCount = 40
+    broadcastBatchSize    = 100
+
+    outboxKindCopy = "copy"
+    outboxKindSend = "send"
+    outboxKindDelete = "delete"
+    outboxKindEdit = "edit"
+)
+
+type outboxCopyPayload struct {
+    FromChatId int `json:"from_chat_id"`
+    MessageId  int `json:"message_id"`
+    // TTLSeconds schedules deleting of the copy from the recipient chat after it is sent
+    TTLSeconds int `json:"ttl_seconds,omitempty"`
+}
+
+// outboxSendPayload sends the captured content, a broadcast takes the content from its campaign
+// so the outbox does not keep a copy of it for every recipient
+type outboxSendPayload struct {
+    Content    *telegram.MessageContent `json:"content,omitempty"`
+    TTLSeconds int                      `json:"ttl_seconds,omitempty"`
+}
+
+// outboxMessagePayload points to a message which was already sent to the chat
+type outboxMessagePayload struct {
+    MessageId int    `json:"message_id"`
+    Text      string `json:"text,omitempty"`
+}
+
+func (h* Handler) enqueueMessage(
+    chatId int, message storage.ForwardMessage, ttl time.Duration, priority telegram.Priority, source string, sourceId int,
+) error {
+    item, err := newMessageItem(chatId, message, ttl, priority, source, sourceId)
+    if err != nil {
+        return err
+    }
+    return h.storage.EnqueueOutbox(context.TODO(), item)
+}
+
+// newMessageItem sends the captured content of the message, messages without it are copied from the admin chat
+func newMessageItem(
+    chatId int, message storage.ForwardMessage, ttl time.Duration, priority telegram.Priority, source string, sourceId int,
+) (storage.OutboxItem, error) {
+    kind := outboxKindCopy
+    var payload []byte
+    var err error
+    if message.Content == "" {
+        payload, err = json.Marshal(outboxCopyPayload{
+            FromChatId: message.FromChatId,
+            MessageId: message.MessageId,
+            TTLSeconds: int(ttl.Seconds()),
+        })
+    } else {
+        kind = outboxKindSend
+        sendPayload := outboxSendPayload{TTLSeconds: int(ttl.Seconds())}
+        if source != storage.OutboxSourceBroadcast {
+            var content telegram.MessageContent
+            if err := json.Unmarshal([]byte(message.Content), &content); err != nil {
+                return storage.OutboxItem{}, helpers.WrapErr(err, "newMessageItem cant unmarshal content")
+            }
+            sendPayload.Content = &content
+        }
+        payload, err = json.Marshal(sendPayload)
+    }
+    if err != nil {
+        return storage.OutboxItem{}, helpers.WrapErr(err, "newMessageItem cant marshal payload")
+    }
+    return storage.OutboxItem{
+        ChatId: chatId,
+        Kind: kind,
+        Payload: string(payload),
+        Priority: int(priority),
+        Source: source,
+        SourceId: sourceId,
+    }, nil
+}
+
+// RecoverOutbox puts back messages which were being sent when the bot stopped
+func (h* Handler) RecoverOutbox() {
+    if err := h.storage.ResetSendingOutbox(context.TODO()); err != nil {
+        log.Println(helpers.WrapErr(err, "cant RecoverOutbox"))
+    }
+}
+
+// DrainOutbox sends one batch of due messages and returns how many of them were taken
+func (h* Handler) DrainOutbox() int {
+    items, err := h.storage.ClaimOutbox(context.TODO(), outboxBatchSize)
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "cant ClaimOutbox in DrainOutbox"))
+        return 0
+    }
+
+    // the client rate limiter keeps workers within telegram limits
+    var wg sync.WaitGroup
+    queue := make(chan storage.OutboxItem)
+    for i := 0; i < outboxWorkers; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for item := range queue {
+                h.processOutboxItem(item)
+            }
+        }()
+    }
+    for _, item := range items {
+        queue <- item
+    }
+    close(queue)
+    wg.Wait()
+
+    return len(items)
+}
+
+func (h* Handler) processOutboxItem(item storage.OutboxItem) {
+    sentMessageIds, err := h.sendOutboxItem(item)
+    if err == nil {
+        if err := h.storage.MarkOutboxSent(context.TODO(), item.Id); err != nil {
+            log.Println(err)
+        }
+        h.afterOutboxItemSent(item, sentMessageIds)
+        return
+    }
+
+    log.Println(helpers.WrapErr(err, "cant send outbox item " + strconv.Itoa(item.Id) + " to chat " + strconv.Itoa(item.ChatId)))
+    h.saveBroadcastDelivery(item, nil, err)
+    // the user will not receive anything until he unblocks the bot, no reason to retry
+    permanent := errors.Is(err, telegram.ErrBotBlocked) || errors.Is(err, telegram.ErrChatNotFound) ||
+        errors.Is(err, telegram.ErrMessageNotFound) || errors.Is(err, telegram.ErrMessageCantBeChanged)
+    if permanent || item.Attempts + 1 >= outboxMaxAttempts {
+        err = h.storage.MarkOutboxFailed(context.TODO(), item.Id, err.Error())
+    } else {
+        nextAttemptAt := time.Now().Add(outboxRetryDelay * time.Duration(item.Attempts + 1))
+        err = h.storage.RescheduleOutbox(context.TODO(), item.Id, err.Error(), nextAttemptAt)
+    }
+    if err != nil {
+        log.Println(err)
+    }
+}
+
+// sendOutboxItem returns ids of messages sent to the recipient, an album has several of them
+func (h* Handler) sendOutboxItem(item storage.OutboxItem) ([]int, error) {
+    switch item.Kind {
+    case outboxKindCopy:
+        var payload outboxCopyPayload
+        if err := json.Unmarshal([]byte(item.Payload), &payload); err != nil {
+            return nil, helpers.WrapErr(err, "cant unmarshal copy payload")
+        }
+        messageId, err := h.client.ForwardMessage(item.ChatId, payload.FromChatId, payload.MessageId, telegram.Priority(item.Priority))
+        if err != nil {
+            return nil, err
+        }
+        return []int{messageId}, nil
+    case outboxKindSend:
+        var payload outboxSendPayload
+        if err := json.Unmarshal([]byte(item.Payload), &payload); err != nil {
+            return nil, helpers.WrapErr(err, "cant unmarshal send payload")
+        }
+        if payload.Content != nil {
+            return h.sendContent(item.ChatId, *payload.Content, telegram.Priority(item.Priority))
+        }
+        campaign, err := h.storage.GetCampaign(context.TODO(), item.SourceId)
+        if err != nil {
+            return nil, helpers.WrapErr(err, "cant get campaign content")
+        }
+        return h.sendStoredMessage(item.ChatId, campaignMessage(campaign), telegram.Priority(item.Priority))
+    case outboxKindDelete, outboxKindEdit:
+        var payload outboxMessagePayload
+        if err := json.Unmarshal([]byte(item.Payload), &payload); err != nil {
+            return nil, helpers.WrapErr(err, "cant unmarshal message payload")
+        }
+        if item.Kind == outboxKindDelete {
+            return []int{payload.MessageId}, h.client.DeleteSentMessage(item.ChatId, payload.MessageId, telegram.Priority(item.Priority))
+        }
+        return []int{payload.MessageId}, h.client.EditSentMessageText(item.ChatId, payload.MessageId, payload.Text, telegram.Priority(item.Priority))
+    default:
+        return nil, errors.New("unknown outbox item kind: " + item.Kind)
+    }
+}
+
+func (h* Handler) afterOutboxItemSent(item storage.OutboxItem, sentMessageIds []int) {
+    switch item.Source {
+    case storage.OutboxSourceRecall, storage.OutboxSourceExpire:
+        // the delivery stays with the deleted status, so the message is not deleted twice and statistics show it
+        delivery := storage.Delivery{
+            BroadcastId: item.SourceId,
+            UserId: item.ChatId,
+            Status: storage.DeliveryStatusDeleted,
+            MessageId: sentMessageIds[0],
+            MessageIds: sentMessageIds,
+            Timestamp: time.Now(),
+        }
+        if err := h.storage.SaveDelivery(context.TODO(), delivery); err != nil {
+            log.Println(helpers.WrapErr(err, "cant SaveDelivery after " + item.Source))
+        }
+    case storage.OutboxSourceBroadcast:
+        // last_message_sent keeps id of the last campaign the user got
+        err := h.storage.UpdateUsersLastMessage(context.TODO(), strconv.Itoa(item.SourceId), []int{item.ChatId})
+        if err != nil {
+            log.Println(helpers.WrapErr(err, "cant UpdateUsersLastMessage after broadcast item sent"))
+        }
+        h.saveBroadcastDelivery(item, sentMessageIds, nil)
+    }
+    if err := h.scheduleMessageExpiry(item, sentMessageIds); err != nil {
+        log.Println(helpers.WrapErr(err, "cant schedule deleting of messages in chat " + strconv.Itoa(item.ChatId)))
+    }
+}
+
+// scheduleMessageExpiry puts deleting of every sent message into the outbox, they wait there until the TTL passes
+func (h* Handler) scheduleMessageExpiry(item storage.OutboxItem, sentMessageIds []int) error {
+    if item.Kind != outboxKindCopy && item.Kind != outboxKindSend {
+        return nil
+    }
+    // both payloads keep the TTL in the same field
+    var sentPayload outboxSendPayload
+    if err := json.Unmarshal([]byte(item.Payload), &sentPayload); err != nil {
+        return helpers.WrapErr(err, "cant unmarshal " + item.Kind + " payload")
+    }
+    if sentPayload.TTLSeconds <= 0 {
+        return nil
+    }
+
+    source := item.Source
+    if source == storage.OutboxSourceBroadcast {
+        source = storage.OutboxSourceExpire
+    }
+    var items []storage.OutboxItem
+    for _, messageId := range sentMessageIds {
+        if messageId <= 0 {
+            continue
+        }
+        payload, err := json.Marshal(outboxMessagePayload{MessageId: messageId})
+        if err != nil {
+            return helpers.WrapErr(err, "cant marshal message payload")
+        }
+        items = append(items, storage.OutboxItem{
+            ChatId: item.ChatId,
+            Kind: outboxKindDelete,
+            Payload: string(payload),
+            Priority: int(telegram.PriorityBulk),
+            NextAttemptAt: time.Now().Add(time.Duration(sentPayload.TTLSeconds) * time.Second),
+            Source: source,
+            SourceId: item.SourceId,
+        })
+    }
+    if len(items) == 0 {
+        return nil
+    }
+    return h.storage.EnqueueOutboxBatch(context.TODO(), items)
+}
+
+func (h* Handler) saveBroadcastDelivery(item storage.OutboxItem, sentMessageIds []int, sendErr error) {
+    if item.Source != storage.OutboxSourceBroadcast {
+        return
+    }
+    delivery := storage.Delivery{
+        BroadcastId: item.SourceId,
+        UserId: item.ChatId,
+        Status: getDeliveryStatus(sendErr),
+        MessageIds: sentMessageIds,
+        Timestamp: time.Now(),
+    }
+    if len(sentMessageIds) > 0 {
+        delivery.MessageId = sentMessageIds[0]
+    }
+    if sendErr != nil {
+        delivery.Error = sendErr.Error()
+    }
+    if err := h.storage.SaveDelivery(context.TODO(), delivery); err != nil {
+        log.Println(helpers.WrapErr(err, "cant SaveDelivery"))
+    }
+}
+
+func getDeliveryStatus(err error) string {
+    switch {
+    case err == nil:
+        return storage.DeliveryStatusSent
+    case errors.Is(err, telegram.ErrBotBlocked):
+        return storage.DeliveryStatusBlocked
+    case errors.Is(err, telegram.ErrChatNotFound):
+        return storage.DeliveryStatusChatNotFound
+    case errors.Is(err, telegram.ErrTooManyRequests):
+        return storage.DeliveryStatusRateLimited
+    default:
+        return storage.DeliveryStatusFailed
+    }
+}
@@ -0,0 +1,115 @@
+package telegram
+
+import (
+    "context"
+    "log"
+    "regexp"
+    "strconv"
+    "time"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{(first_name|last_name|username|channel_title|join_date)\}`)
+
+func hasPlaceholders(content telegram.MessageContent) bool {
+    if placeholderPattern.MatchString(content.Text) {
+        return true
+    }
+    for _, media := range content.Media {
+        if placeholderPattern.MatchString(media.Caption) {
+            return true
+        }
+    }
+    return false
+}
+
+// renderContent puts data of the recipient into placeholders, the text with placeholders is sent in the HTML parse mode
+// with escaped values, so names with < or & do not break the formatting
+func (h* Handler) renderContent(chatId int, content telegram.MessageContent) telegram.MessageContent {
+    if !hasPlaceholders(content) {
+        return content
+    }
+    values := h.getPlaceholderValues(h.getRecipientProfile(chatId))
+    render := func(text string, entities []telegram.MessageEntity) string {
+        return placeholderPattern.ReplaceAllStringFunc(telegram.EntitiesToHTML(text, entities), func(placeholder string) string {
+            return telegram.EscapeHTML(values[placeholder])
+        })
+    }
+
+    if content.Text != "" {
+        content.Text = render(content.Text, content.Entities)
+        content.Entities = nil
+        content.ParseMode = telegram.ParseModeHTML
+    }
+    media := make([]telegram.InputMedia, len(content.Media))
+    for i, item := range content.Media {
+        if placeholderPattern.MatchString(item.Caption) {
+            item.Caption = render(item.Caption, item.CaptionEntities)
+            item.CaptionEntities = nil
+            item.ParseMode = telegram.ParseModeHTML
+        }
+        media[i] = item
+    }
+    content.Media = media
+    return content
+}
+
+// getRecipientProfile finds the user in the database, admins who are not there get their telegram profile,
+// so a preview shows the message like it is rendered for the admin
+func (h* Handler) getRecipientProfile(chatId int) storage.User {
+    user, err := h.storage.GetUser(context.TODO(), chatId)
+    if err == nil && user.Id > 0 {
+        return user
+    }
+    chat, err := h.client.GetChat(chatId)
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "cant get profile of chat " + strconv.Itoa(chatId)))
+    }
+    return storage.User{
+        Id: chatId,
+        FirstName: chat.FirstName,
+        LastName: chat.LastName,
+        Username: chat.Username,
+        Timestamp: time.Now(),
+    }
+}
+
+func (h* Handler) getPlaceholderValues(user storage.User) map[string]string {
+    values := map[string]string{
+        "{first_name}": user.FirstName,
+        "{last_name}": user.LastName,
+        "{username}": user.Username,
+        "{channel_title}": "",
+        "{join_date}": "",
+    }
+    if user.Timestamp.Unix() > 0 {
+        values["{join_date}"] = user.Timestamp.Format(AudienceDateFormat)
+    }
+    // the channel the user joined last is the one he got the message for
+    if len(user.ChannelsIds) > 0 {
+        values["{channel_title}"] = h.getChatTitle(user.ChannelsIds[len(user.ChannelsIds) - 1])
+    }
+    return values
+}
+
+// getChatTitle asks telegram for the title once and keeps it while the bot works
+func (h* Handler) getChatTitle(chatId string) string {
+    h.chatTitlesMu.Lock()
+    defer h.chatTitlesMu.Unlock()
+    if title, ok := h.chatTitles[chatId]; ok {
+        return title
+    }
+    id, err := strconv.Atoi(chatId)
+    if err != nil {
+        return ""
+    }
+    chat, err := h.client.GetChat(id)
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "cant get title of chat " + chatId))
+        return ""
+    }
+    h.chatTitles[chatId] = chat.Title
+    return chat.Title
+}
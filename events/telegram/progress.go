@@ -0,0 +1,139 @@
+package telegram
+
+import (
+    "context"
+    "log"
+    "strconv"
+    "time"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/helpers"
+    "user-handler-bot/messages"
+    "user-handler-bot/storage"
+)
+
+const (
+    progressUpdateInterval = 15 * time.Second
+    progressRateWindow     = time.Minute
+)
+
+// reportCampaignProgress posts the progress of sending to the admin who started the campaign and edits it later,
+// a done or cancelled campaign gets the final summary
+func (h* Handler) reportCampaignProgress(campaignId int, force bool) {
+    h.progressMu.Lock()
+    defer h.progressMu.Unlock()
+
+    if !force && time.Since(h.progressUpdatedAt[campaignId]) < progressUpdateInterval {
+        return
+    }
+    campaign, err := h.storage.GetCampaign(context.TODO(), campaignId)
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "cant get campaign for progress"))
+        return
+    }
+    if campaign.ProgressChatId == 0 {
+        return
+    }
+    text, err := h.formatCampaignProgress(campaign)
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "cant make campaign progress"))
+        return
+    }
+
+    msg := h.makeHTMLInlineKeyBoard(campaign.ProgressChatId, campaign.ProgressMessageId, text, h.getProgressInlineKeyBoard(campaign))
+    if campaign.ProgressMessageId == 0 {
+        messageId, err := h.client.SendMessageWithKeyBoard(msg)
+        if err != nil {
+            log.Println(helpers.WrapErr(err, "cant send campaign progress"))
+            return
+        }
+        err = h.storage.SetCampaignProgressMessage(context.TODO(), campaign.Id, campaign.ProgressChatId, messageId)
+        if err != nil {
+            log.Println(err)
+        }
+    } else if err := h.client.EditMessageWithKeyBoard(msg); err != nil {
+        log.Println(helpers.WrapErr(err, "cant update campaign progress"))
+    }
+
+    if isCampaignFinished(campaign) {
+        delete(h.progressUpdatedAt, campaign.Id)
+    } else {
+        h.progressUpdatedAt[campaign.Id] = time.Now()
+    }
+}
+
+// formatCampaignProgress shows the progress of the campaign as HTML
+func (h* Handler) formatCampaignProgress(campaign storage.Campaign) (string, error) {
+    recipients, err := h.storage.GetCountAudience(context.TODO(), campaign.Audience)
+    if err != nil {
+        return "", err
+    }
+    deliveryStats, err := h.storage.GetDeliveryStats(context.TODO(), campaign.Id)
+    if err != nil {
+        return "", err
+    }
+    sent := deliveryStats[storage.DeliveryStatusSent]
+    processed := 0
+    for _, count := range deliveryStats {
+        processed += count
+    }
+
+    if isCampaignFinished(campaign) {
+        outboxStats, err := h.storage.GetOutboxSourceStats(context.TODO(), storage.OutboxSourceBroadcast, campaign.Id)
+        if err != nil {
+            return "", err
+        }
+        text := messages.PROGRESS_SENT + strconv.Itoa(sent) + messages.PROGRESS_OF + strconv.Itoa(recipients)
+        if outboxStats[storage.OutboxStatusCancelled] > 0 {
+            text += "\n" + messages.CANCELLED_NOT_SENT + strconv.Itoa(outboxStats[storage.OutboxStatusCancelled])
+        }
+        return formatCampaignTitle(messages.CAMPAIGN_FINISHED, campaign) + telegram.EscapeHTML(text) + "\n\n" + formatDeliveryStats(deliveryStats), nil
+    }
+
+    remaining := recipients - processed
+    if remaining < 0 {
+        remaining = 0
+    }
+    rate, err := h.storage.GetCountDeliveriesSince(context.TODO(), campaign.Id, time.Now().Add(-progressRateWindow))
+    if err != nil {
+        return "", err
+    }
+
+    text := messages.PROGRESS_SENT + strconv.Itoa(sent) + messages.PROGRESS_OF + strconv.Itoa(recipients) + "\n" +
+        messages.PROGRESS_FAILED + strconv.Itoa(processed - sent) + "\n" +
+        messages.PROGRESS_REMAINING + strconv.Itoa(remaining) + "\n" +
+        messages.PROGRESS_RATE + strconv.Itoa(rate)
+    if rate > 0 && remaining > 0 && campaign.Status == storage.CampaignStatusSending {
+        eta := time.Duration(float64(remaining) / float64(rate) * float64(progressRateWindow))
+        text += "\n" + messages.PROGRESS_ETA + eta.Round(time.Second).String()
+    }
+    return formatCampaignTitle(messages.CAMPAIGN_IN_PROGRESS, campaign) + telegram.EscapeHTML(text), nil
+}
+
+// formatCampaignTitle is the HTML line with the name and the status of the campaign
+func formatCampaignTitle(title string, campaign storage.Campaign) string {
+    return telegram.EscapeHTML(title) + telegram.BoldHTML(campaign.Name) + " [" + campaign.Status + "]\n"
+}
+
+func isCampaignFinished(campaign storage.Campaign) bool {
+    return campaign.Status == storage.CampaignStatusDone || campaign.Status == storage.CampaignStatusCancelled
+}
+
+func (h* Handler) getProgressInlineKeyBoard(campaign storage.Campaign) telegram.InlineKeyboardMarkup {
+    var buttons [][]telegram.InlineKeyboardButton
+    switch campaign.Status {
+    case storage.CampaignStatusSending:
+        buttons = append(buttons, []telegram.InlineKeyboardButton{
+            {Text: messages.KEYBOARD_PAUSE, CallbackData: makeCallback(PauseCampaign, campaign.Id)},
+            {Text: messages.KEYBOARD_CANCEL_CAMPAIGN, CallbackData: makeCallback(CancelCampaign, campaign.Id)},
+        })
+    case storage.CampaignStatusPaused:
+        buttons = append(buttons, []telegram.InlineKeyboardButton{
+            {Text: messages.KEYBOARD_RESUME, CallbackData: makeCallback(ResumeCampaign, campaign.Id)},
+            {Text: messages.KEYBOARD_CANCEL_CAMPAIGN, CallbackData: makeCallback(CancelCampaign, campaign.Id)},
+        })
+    }
+    buttons = append(buttons, []telegram.InlineKeyboardButton{
+        {Text: messages.KEYBOARD_STATISTIC, CallbackData: makeCallback(CampaignStatistics, campaign.Id)},
+    })
+    return telegram.InlineKeyboardMarkup{InlineKeyboard: buttons}
+}
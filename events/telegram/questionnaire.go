@@ -0,0 +1,124 @@
+package telegram
+
+import (
+    "encoding/json"
+    "errors"
+    "log"
+    "os"
+    "regexp"
+    "strings"
+    "unicode/utf8"
+    "user-handler-bot/helpers"
+)
+
+// questionnaireFile keeps questions for users who send requests to join, there is no questionnaire without the file.
+// The file looks like
+//  {"questions": [
+//      {"id": "age", "text": "How old are you?", "options": ["under 18", "18 or older"], "accept": ["18 or older"]},
+//      {"id": "why", "text": "Why do you want to join?", "min_length": 10, "pattern": "(?i)[a-z]"}
+//  ]}
+// Requests whose answers pass all questions are approved, the others are sent to admins
+const questionnaireFile = "questionnaire.json"
+
+type questionnaire struct {
+    Questions []question `json:"questions"`
+}
+
+type question struct {
+    Id   string `json:"id"`
+    Text string `json:"text"`
+    // Options are answers shown as buttons, the user can not type an own answer then
+    Options []string `json:"options"`
+    // Accept are the options which pass, any option passes when it is empty
+    Accept []string `json:"accept"`
+    // MinLength and Pattern check a typed answer
+    MinLength int    `json:"min_length"`
+    Pattern   string `json:"pattern"`
+    pattern   *regexp.Regexp
+}
+
+func loadQuestionnaire() questionnaire {
+    data, err := os.ReadFile(questionnaireFile)
+    if os.IsNotExist(err) {
+        return questionnaire{}
+    }
+    if err != nil {
+        log.Fatal(helpers.WrapErr(err, "cant read " + questionnaireFile))
+    }
+    var result questionnaire
+    if err := json.Unmarshal(data, &result); err != nil {
+        log.Fatal(helpers.WrapErr(err, "cant parse " + questionnaireFile))
+    }
+    if err := result.prepare(); err != nil {
+        log.Fatal(helpers.WrapErr(err, "wrong " + questionnaireFile))
+    }
+    return result
+}
+
+// prepare checks the questions and compiles their patterns
+func (q *questionnaire) prepare() error {
+    ids := make(map[string]bool)
+    for i := range q.Questions {
+        question := &q.Questions[i]
+        if question.Id == "" || question.Text == "" {
+            return errors.New("every question needs id and text")
+        }
+        if ids[question.Id] {
+            return errors.New("question id " + question.Id + " is repeated")
+        }
+        ids[question.Id] = true
+        if len(question.Options) >= screeningOptionsLimit {
+            return errors.New("question " + question.Id + " has more options than buttons telegram shows")
+        }
+        for _, accepted := range question.Accept {
+            if !containsString(question.Options, accepted) {
+                return errors.New("question " + question.Id + " accepts " + accepted + " which is not an option")
+            }
+        }
+        if question.Pattern != "" {
+            pattern, err := regexp.Compile(question.Pattern)
+            if err != nil {
+                return helpers.WrapErr(err, "question " + question.Id + " pattern")
+            }
+            question.pattern = pattern
+        }
+    }
+    return nil
+}
+
+func (q questionnaire) enabled() bool {
+    return len(q.Questions) > 0
+}
+
+// passes tells whether the answers can be approved without admins
+func (q questionnaire) passes(answers map[string]string) bool {
+    for _, question := range q.Questions {
+        if !question.accepts(answers[question.Id]) {
+            return false
+        }
+    }
+    return true
+}
+
+func (q question) accepts(answer string) bool {
+    if len(q.Options) > 0 {
+        if len(q.Accept) == 0 {
+            return containsString(q.Options, answer)
+        }
+        return containsString(q.Accept, answer)
+    }
+    answer = strings.TrimSpace(answer)
+    if answer == "" || utf8.RuneCountInString(answer) < q.MinLength {
+        return false
+    }
+    return q.pattern == nil || q.pattern.MatchString(answer)
+}
+
+func containsString(values []string, value string) bool {
+    for _, item := range values {
+        if item == value {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,141 @@
+package telegram
+
+import (
+    "context"
+    "encoding/json"
+    "strconv"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/helpers"
+    "user-handler-bot/messages"
+    "user-handler-bot/storage"
+)
+
+const (
+    RecallCampaign = "/campaign-recall"
+    ConfirmRecallCampaign = "/campaign-recall-confirm"
+    EditCampaignText = "/campaign-edit-text"
+)
+
+const (
+    campaignInputEditText = "edit-text"
+
+    allDeliveries = -1
+)
+
+// changeCampaignEverywhere queues deleting or editing of the campaign message in chats of all users who got it,
+// the outbox worker does it under the same rate limits as sending
+func (h* Handler) changeCampaignEverywhere(chatId int, messageId int, campaign storage.Campaign, kind string, text string) error {
+    if !isCampaignFinished(campaign) {
+        return h.answerCampaignChange(chatId, messageId, messages.CAMPAIGN_CANT_BE_CHANGED, campaign)
+    }
+
+    source := storage.OutboxSourceRecall
+    if kind == outboxKindEdit {
+        source = storage.OutboxSourceEdit
+    }
+    stats, err := h.storage.GetOutboxSourceStats(context.TODO(), source, campaign.Id)
+    if err != nil {
+        return err
+    }
+    if stats[storage.OutboxStatusPending] + stats[storage.OutboxStatusSending] > 0 {
+        return h.answerCampaignChange(chatId, messageId, messages.CAMPAIGN_CHANGE_IN_PROGRESS, campaign)
+    }
+
+    deliveries, err := h.storage.GetDeliveries(context.TODO(), campaign.Id, []string{storage.DeliveryStatusSent}, allDeliveries)
+    if err != nil {
+        return helpers.WrapErr(err, "cant get deliveries to change campaign")
+    }
+    var items []storage.OutboxItem
+    for _, delivery := range deliveries {
+        messageIds := delivery.MessageIds
+        if kind == outboxKindEdit {
+            messageIds = captionedMessageIds(campaign, messageIds)
+        }
+        for _, messageId := range messageIds {
+            if messageId <= 0 {
+                continue
+            }
+            payload, err := json.Marshal(outboxMessagePayload{MessageId: messageId, Text: text})
+            if err != nil {
+                return helpers.WrapErr(err, "cant marshal message payload")
+            }
+            items = append(items, storage.OutboxItem{
+                ChatId: delivery.UserId,
+                Kind: kind,
+                Payload: string(payload),
+                Priority: int(telegram.PriorityBulk),
+                Source: source,
+                SourceId: campaign.Id,
+            })
+        }
+    }
+    if err := h.storage.EnqueueOutboxBatch(context.TODO(), items); err != nil {
+        return helpers.WrapErr(err, "cant enqueue campaign change")
+    }
+    return h.answerCampaignChange(chatId, messageId, messages.CAMPAIGN_CHANGE_QUEUED + strconv.Itoa(len(items)), campaign)
+}
+
+// captionedMessageIds returns messages of the album which have a caption, the text of others can not be edited.
+// An album without captions gets the text on its first message
+func captionedMessageIds(campaign storage.Campaign, messageIds []int) []int {
+    var content telegram.MessageContent
+    if len(messageIds) < 2 || json.Unmarshal([]byte(campaign.Content), &content) != nil || len(content.Media) != len(messageIds) {
+        return messageIds
+    }
+    var result []int
+    for i, media := range content.Media {
+        if media.Caption != "" {
+            result = append(result, messageIds[i])
+        }
+    }
+    if len(result) == 0 {
+        return messageIds[:1]
+    }
+    return result
+}
+
+func (h* Handler) answerCampaignChange(chatId int, messageId int, text string, campaign storage.Campaign) error {
+    msg := h.makeInlineKeyBoard(chatId, messageId, text, h.getCampaignStatisticsInlineKeyBoard(campaign.Id))
+    if messageId == 0 {
+        return h.client.SendInlineKeyBoard(msg)
+    }
+    return h.client.UpdateInlineKeyBoard(msg)
+}
+
+// formatCampaignChanges shows how many messages were deleted or edited in users chats, by admins or after the TTL
+func (h* Handler) formatCampaignChanges(campaignId int) (string, error) {
+    text := ""
+    for _, change := range []struct {
+        source string
+        title  string
+    }{
+        {storage.OutboxSourceRecall, messages.CAMPAIGN_DELETED_EVERYWHERE},
+        {storage.OutboxSourceEdit, messages.CAMPAIGN_EDITED_EVERYWHERE},
+        {storage.OutboxSourceExpire, messages.CAMPAIGN_EXPIRED},
+    } {
+        stats, err := h.storage.GetOutboxSourceStats(context.TODO(), change.source, campaignId)
+        if err != nil {
+            return "", err
+        }
+        if len(stats) == 0 {
+            continue
+        }
+        queued := stats[storage.OutboxStatusPending] + stats[storage.OutboxStatusSending]
+        text += "\n" + change.title + strconv.Itoa(stats[storage.OutboxStatusSent]) +
+            messages.CAMPAIGN_CHANGE_FAILED + strconv.Itoa(stats[storage.OutboxStatusFailed]) +
+            messages.CAMPAIGN_CHANGE_REMAINING + strconv.Itoa(queued)
+    }
+    return text, nil
+}
+
+func (h* Handler) getRecallCampaignInlineKeyBoard(campaignId int) telegram.InlineKeyboardMarkup {
+    return telegram.InlineKeyboardMarkup{
+        InlineKeyboard: [][]telegram.InlineKeyboardButton{
+        {
+            {Text: messages.KEYBOARD_CONFIRM_RECALL_CAMPAIGN, CallbackData: makeCallback(ConfirmRecallCampaign, campaignId)},
+        },
+        {
+            {Text: messages.KEYBOARD_BACK_TO_CAMPAIGN, CallbackData: makeCallback(ShowCampaign, campaignId)},
+        },
+    },}
+}
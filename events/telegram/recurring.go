@@ -0,0 +1,209 @@
+package telegram
+
+import (
+    "context"
+    "log"
+    "strconv"
+    "strings"
+    "time"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/helpers"
+    "user-handler-bot/messages"
+    "user-handler-bot/storage"
+)
+
+const (
+    RecurringBroadcasts = "/schedules"
+    ShowRecurringBroadcast = "/schedule"
+    PauseRecurringBroadcast = "/schedule-pause"
+    ResumeRecurringBroadcast = "/schedule-resume"
+    DeleteRecurringBroadcast = "/schedule-delete"
+)
+
+// CheckRecurringBroadcasts starts a new campaign run for every recurring broadcast whose time has come.
+// Runs missed while the bot was stopped are started once, then the schedule continues from now
+func (h* Handler) CheckRecurringBroadcasts() {
+    recurringBroadcasts, err := h.storage.GetDueRecurringBroadcasts(context.TODO())
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "Cant get recurring broadcasts for CheckRecurringBroadcasts"))
+        return
+    }
+    for _, recurring := range recurringBroadcasts {
+        if err := h.startRecurringBroadcast(recurring); err != nil {
+            log.Println(helpers.WrapErr(err, "startRecurringBroadcast from CheckRecurringBroadcasts id: " + strconv.Itoa(recurring.Id)))
+        }
+    }
+}
+
+func (h* Handler) startRecurringBroadcast(recurring storage.RecurringBroadcast) error {
+    schedule, err := helpers.ParseSchedule(recurring.Schedule)
+    if err != nil {
+        return helpers.WrapErr(err, "cant parse schedule " + recurring.Schedule)
+    }
+    template, err := h.storage.GetCampaign(context.TODO(), recurring.CampaignId)
+    if err != nil {
+        return helpers.WrapErr(err, "cant get campaign of recurring broadcast")
+    }
+
+    now := time.Now()
+    nextRun := schedule.Next(now)
+    if nextRun.IsZero() {
+        return h.storage.SetRecurringBroadcastPaused(context.TODO(), recurring.Id, true, recurring.NextRun)
+    }
+    run := storage.Campaign{
+        Name: template.Name + " " + now.Format(LastMessageForAllFormat),
+        FromChatId: template.FromChatId,
+        MessageId: template.MessageId,
+        Content: template.Content,
+        TimeToSent: now,
+        Audience: template.Audience,
+        Status: storage.CampaignStatusScheduled,
+        CreatedBy: template.CreatedBy,
+        ProgressChatId: template.CreatedBy,
+        TTL: template.TTL,
+    }
+    if template.MessageId <= 0 {
+        // the run is skipped but the schedule goes on, the admin may set the message later
+        log.Println("recurring broadcast " + strconv.Itoa(recurring.Id) + " skipped, campaign has no message")
+        return h.storage.SetRecurringBroadcastPaused(context.TODO(), recurring.Id, false, nextRun)
+    }
+    _, err = h.storage.StartRecurringBroadcast(context.TODO(), recurring, run, nextRun)
+    return err
+}
+
+func (h* Handler) answerRecurringBroadcastCallback(chatId int, messageId int, command string, recurringId int) error {
+    recurring, err := h.storage.GetRecurringBroadcast(context.TODO(), recurringId)
+    if err != nil {
+        log.Println(err)
+        return h.sendRecurringBroadcasts(chatId, messageId, messages.RECURRING_BROADCAST_NOT_FOUND)
+    }
+
+    switch command {
+    case ShowRecurringBroadcast:
+    case PauseRecurringBroadcast:
+        recurring.Paused = true
+    case ResumeRecurringBroadcast:
+        schedule, err := helpers.ParseSchedule(recurring.Schedule)
+        if err != nil {
+            return helpers.WrapErr(err, "cant parse schedule " + recurring.Schedule)
+        }
+        recurring.Paused = false
+        recurring.NextRun = schedule.Next(time.Now())
+    case DeleteRecurringBroadcast:
+        if err := h.storage.DeleteRecurringBroadcast(context.TODO(), recurring.Id); err != nil {
+            return err
+        }
+        return h.sendRecurringBroadcasts(chatId, messageId, messages.RECURRING_BROADCAST_DELETED)
+    default:
+        return h.client.SendMessage(chatId, "Command not found")
+    }
+
+    if command != ShowRecurringBroadcast {
+        err := h.storage.SetRecurringBroadcastPaused(context.TODO(), recurring.Id, recurring.Paused, recurring.NextRun)
+        if err != nil {
+            return err
+        }
+    }
+    return h.client.UpdateInlineKeyBoard(
+        h.makeHTMLInlineKeyBoard(chatId, messageId, h.formatRecurringBroadcast(recurring), h.getRecurringBroadcastInlineKeyBoard(recurring)),
+    )
+}
+
+// addRecurringBroadcast handles the schedule sent by the admin for the campaign
+func (h* Handler) addRecurringBroadcast(chatId int, campaign storage.Campaign, spec string) error {
+    spec = strings.TrimSpace(spec)
+    schedule, err := helpers.ParseSchedule(spec)
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "recurring broadcast schedule parse error"))
+        return h.client.SendInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, 0, messages.ERR_PARSE_SCHEDULE + "\n" + err.Error(), h.getCampaignInlineKeyBoard(campaign)),
+        )
+    }
+    if campaign.MessageId <= 0 {
+        return h.client.SendInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, 0, messages.ERR_MSG_TO_ALL_NOT_FOUND, h.getCampaignInlineKeyBoard(campaign)),
+        )
+    }
+
+    recurring := storage.RecurringBroadcast{
+        CampaignId: campaign.Id,
+        Schedule: spec,
+        NextRun: schedule.Next(time.Now()),
+    }
+    if recurring.NextRun.IsZero() {
+        return h.client.SendInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, 0, messages.ERR_PARSE_SCHEDULE, h.getCampaignInlineKeyBoard(campaign)),
+        )
+    }
+    recurring.Id, err = h.storage.CreateRecurringBroadcast(context.TODO(), recurring)
+    if err != nil {
+        return helpers.WrapErr(err, "cant create recurring broadcast")
+    }
+    return h.client.SendInlineKeyBoard(
+        h.makeHTMLInlineKeyBoard(chatId, 0, h.formatRecurringBroadcast(recurring), h.getRecurringBroadcastInlineKeyBoard(recurring)),
+    )
+}
+
+func (h* Handler) sendRecurringBroadcasts(chatId int, messageId int, text string) error {
+    recurringBroadcasts, err := h.storage.GetRecurringBroadcasts(context.TODO())
+    if err != nil {
+        return helpers.WrapErr(err, "cant get recurring broadcasts")
+    }
+    var buttons [][]telegram.InlineKeyboardButton
+    for _, recurring := range recurringBroadcasts {
+        name := strconv.Itoa(recurring.CampaignId)
+        if campaign, err := h.storage.GetCampaign(context.TODO(), recurring.CampaignId); err == nil {
+            name = campaign.Name
+        }
+        if recurring.Paused {
+            name += " [" + messages.RECURRING_BROADCAST_PAUSED + "]"
+        }
+        buttons = append(buttons, []telegram.InlineKeyboardButton{{
+            Text: name + " (" + recurring.Schedule + ")",
+            CallbackData: makeCallback(ShowRecurringBroadcast, recurring.Id),
+        }})
+    }
+    buttons = append(buttons, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_GET_BACK, CallbackData: GetBack}})
+
+    return h.client.UpdateInlineKeyBoard(
+        h.makeInlineKeyBoard(chatId, messageId, text, telegram.InlineKeyboardMarkup{InlineKeyboard: buttons}),
+    )
+}
+
+// formatRecurringBroadcast shows the recurring broadcast as HTML
+func (h* Handler) formatRecurringBroadcast(recurring storage.RecurringBroadcast) string {
+    text := telegram.EscapeHTML(messages.CAMPAIGN)
+    if campaign, err := h.storage.GetCampaign(context.TODO(), recurring.CampaignId); err == nil {
+        text += telegram.BoldHTML(campaign.Name)
+    }
+    text += "\n" + telegram.EscapeHTML(messages.RECURRING_BROADCAST_SCHEDULE) + "<code>" + telegram.EscapeHTML(recurring.Schedule) + "</code>\n"
+    if recurring.Paused {
+        text += telegram.EscapeHTML(messages.RECURRING_BROADCAST_PAUSED)
+    } else if recurring.NextRun.Unix() > 0 {
+        text += telegram.EscapeHTML(messages.RECURRING_BROADCAST_NEXT_RUN) + recurring.NextRun.Format(LastMessageForAllFormat)
+    }
+    if recurring.LastRun.Unix() > 0 {
+        text += "\n" + telegram.EscapeHTML(messages.RECURRING_BROADCAST_LAST_RUN) + recurring.LastRun.Format(LastMessageForAllFormat)
+    }
+    return text
+}
+
+func (h* Handler) getRecurringBroadcastInlineKeyBoard(recurring storage.RecurringBroadcast) telegram.InlineKeyboardMarkup {
+    pause := telegram.InlineKeyboardButton{Text: messages.KEYBOARD_PAUSE, CallbackData: makeCallback(PauseRecurringBroadcast, recurring.Id)}
+    if recurring.Paused {
+        pause = telegram.InlineKeyboardButton{Text: messages.KEYBOARD_RESUME, CallbackData: makeCallback(ResumeRecurringBroadcast, recurring.Id)}
+    }
+    return telegram.InlineKeyboardMarkup{
+        InlineKeyboard: [][]telegram.InlineKeyboardButton{
+        {
+            pause,
+            {Text: messages.KEYBOARD_DELETE_RECURRING_BROADCAST, CallbackData: makeCallback(DeleteRecurringBroadcast, recurring.Id)},
+        },
+        {
+            {Text: messages.KEYBOARD_BACK_TO_CAMPAIGN, CallbackData: makeCallback(ShowCampaign, recurring.CampaignId)},
+        },
+        {
+            {Text: messages.KEYBOARD_RECURRING_BROADCASTS, CallbackData: RecurringBroadcasts},
+        },
+    },}
+}
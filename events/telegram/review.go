@@ -0,0 +1,208 @@
+package telegram
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "log"
+    "strconv"
+    "strings"
+    "time"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/events"
+    "user-handler-bot/helpers"
+    "user-handler-bot/messages"
+    "user-handler-bot/storage"
+)
+
+const (
+    // ReviewRequests has the position of the request in the queue
+    ReviewRequests = "/review"
+    // ApproveReviewRequest and DeclineReviewRequest have two parameters: the request and its position in the queue
+    ApproveReviewRequest = "/review-approve"
+    DeclineReviewRequest = "/review-decline"
+)
+
+// answerReviewCallback shows requests to join which were not approved automatically one at a time
+func (h* Handler) answerReviewCallback(chatId int, messageId int, command string, admin telegram.User) error {
+    if action, requestId, position, ok := parsePairCallback(command); ok {
+        text, err := h.decideRequestToJoin(admin, action == ApproveReviewRequest, requestId)
+        if err != nil {
+            return err
+        }
+        return h.sendReviewRequest(chatId, messageId, position, text)
+    }
+    _, position, _ := parseCallback(command)
+    return h.sendReviewRequest(chatId, messageId, position, "")
+}
+
+// decideRequestToJoin approves or declines the request and returns the text for the admin,
+// the request is claimed first so two admins can not decide it both
+func (h* Handler) decideRequestToJoin(admin telegram.User, approve bool, requestId int) (string, error) {
+    request, claimed, err := h.storage.ClaimRequestToJoin(context.TODO(), requestId, admin.Id)
+    if err != nil {
+        return "", err
+    }
+    if !claimed {
+        return messages.REQUEST_ALREADY_HANDLED, nil
+    }
+    event, err := parseRequestToJoinEvent(request.Event)
+    if err != nil {
+        // a broken request can not be decided, it is removed from the queue
+        log.Println(err)
+        return messages.REQUEST_ALREADY_HANDLED, h.storage.DeleteRequestToJoin(context.TODO(), requestId)
+    }
+    joinRequest := event.Meta.(*telegram.ChatJoinRequest)
+
+    text := messages.REQUEST_ALREADY_HANDLED
+    if approve {
+        ok, err := h.saveUsersIntoDbAndApproveRequestToJoin(event)
+        if err != nil {
+            return "", h.releaseRequestToJoin(requestId, err)
+        }
+        if ok {
+            text = messages.REQUEST_APPROVED
+            if err := h.logJoinDecision(joinRequest, storage.JoinDecisionApproved, formatDecider(admin)); err != nil {
+                log.Println(err)
+            }
+            if err := h.SentMessageToUserAfterAcceptRequestJoin(event); err != nil {
+                log.Println(err)
+            }
+        }
+    } else {
+        ok, err := h.client.DeclineChatJoinRequest(joinRequest.User.Id, joinRequest.Chat.Id)
+        if err != nil && !errors.Is(err, telegram.ErrJoinRequestMissing) {
+            err = helpers.WrapErr(err, "cant decline request to join userId: " + strconv.Itoa(joinRequest.User.Id))
+            return "", h.releaseRequestToJoin(requestId, err)
+        }
+        if ok {
+            text = messages.REQUEST_DECLINED
+            if err := h.logJoinDecision(joinRequest, storage.JoinDecisionDeclined, formatDecider(admin)); err != nil {
+                log.Println(err)
+            }
+        }
+    }
+
+    if err := h.storage.DeleteRequestToJoin(context.TODO(), requestId); err != nil {
+        return "", err
+    }
+    h.updateRequestCards(requestId, h.formatRequestToJoin(joinRequest, requestId) + "\n\n" + telegram.BoldHTML(text) + "\n" +
+        telegram.EscapeHTML(messages.REQUEST_DECIDED_BY + formatAdminName(admin)))
+    return text, nil
+}
+
+// formatDecider tells in the log who decided the request, the questionnaire or an admin
+func formatDecider(admin telegram.User) string {
+    if admin.Id == screeningDecider.Id {
+        return "questionnaire"
+    }
+    return "admin: " + formatAdminName(admin)
+}
+
+// releaseRequestToJoin lets admins decide the request again after the failed decision
+func (h* Handler) releaseRequestToJoin(requestId int, decisionErr error) error {
+    if err := h.storage.ReleaseRequestToJoin(context.TODO(), requestId); err != nil {
+        log.Println(err)
+    }
+    return decisionErr
+}
+
+func (h* Handler) sendReviewRequest(chatId int, messageId int, position int, notice string) error {
+    total, err := h.storage.GetCountPendingRequestsToJoin(context.TODO())
+    if err != nil {
+        return err
+    }
+    if notice != "" {
+        notice += "\n\n"
+    }
+    if total == 0 {
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, notice + messages.REVIEW_QUEUE_EMPTY, h.getBackToStartInlineKeyBoard()),
+        )
+    }
+    // the last request could have been handled, then the previous one is shown
+    if position >= total {
+        position = total - 1
+    }
+    if position < 0 {
+        position = 0
+    }
+    requests, err := h.storage.GetPendingRequestsToJoin(context.TODO(), position, 1)
+    if err != nil {
+        return err
+    }
+    if len(requests) == 0 {
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, notice + messages.REVIEW_QUEUE_EMPTY, h.getBackToStartInlineKeyBoard()),
+        )
+    }
+    event, err := parseRequestToJoinEvent(requests[0].Event)
+    if err != nil {
+        return err
+    }
+
+    text := telegram.EscapeHTML(notice + messages.REVIEW_REQUEST + strconv.Itoa(position + 1) + "/" + strconv.Itoa(total)) + "\n" +
+        h.formatRequestToJoin(event.Meta.(*telegram.ChatJoinRequest), requests[0].Id)
+    return h.client.UpdateInlineKeyBoard(
+        h.makeHTMLInlineKeyBoard(chatId, messageId, text, h.getReviewInlineKeyBoard(requests[0].Id, position, total)),
+    )
+}
+
+func parseRequestToJoinEvent(data string) (events.Event, error) {
+    var delayEvent DelayedRequest
+    if err := json.Unmarshal([]byte(data), &delayEvent); err != nil {
+        return events.Event{}, helpers.WrapErr(err, "cant unmarshal request to join")
+    }
+    if delayEvent.Meta == nil {
+        return events.Event{}, errors.New("request to join has no meta")
+    }
+    return events.Event{Type: delayEvent.Type, Text: delayEvent.Text, Meta: delayEvent.Meta}, nil
+}
+
+// formatRequestToJoin shows the user who sent the request with answers to the questionnaire as HTML
+func (h* Handler) formatRequestToJoin(request *telegram.ChatJoinRequest, requestId int) string {
+    name := strings.TrimSpace(request.User.FirstName + " " + request.User.LastName)
+    text := telegram.EscapeHTML(messages.REVIEW_REQUEST_NAME) + telegram.BoldHTML(name) + " (" + strconv.Itoa(request.User.Id) + ")"
+    if request.User.Username != "" {
+        text += "\n" + telegram.EscapeHTML(messages.REVIEW_REQUEST_USERNAME + "@" + request.User.Username)
+    }
+    if request.Bio != "" {
+        text += "\n" + telegram.EscapeHTML(messages.REVIEW_REQUEST_BIO + request.Bio)
+    }
+    channel := strconv.Itoa(request.Chat.Id)
+    if request.Chat.Title != "" {
+        channel = request.Chat.Title + " (" + channel + ")"
+    }
+    text += "\n" + telegram.EscapeHTML(messages.REVIEW_REQUEST_CHANNEL + channel)
+    if request.Date > 0 {
+        text += "\n" + telegram.EscapeHTML(messages.REVIEW_REQUEST_DATE) + time.Unix(int64(request.Date), 0).Format(LastMessageForAllFormat)
+    }
+    return text + h.formatScreeningAnswers(requestId)
+}
+
+func (h* Handler) getReviewInlineKeyBoard(requestId int, position int, total int) telegram.InlineKeyboardMarkup {
+    // skipping the last request starts the queue again
+    next := position + 1
+    if next >= total {
+        next = 0
+    }
+    var pages []telegram.InlineKeyboardButton
+    if position > 0 {
+        pages = append(pages, telegram.InlineKeyboardButton{Text: messages.KEYBOARD_PREVIOUS_PAGE, CallbackData: makeCallback(ReviewRequests, position - 1)})
+    }
+    if position + 1 < total {
+        pages = append(pages, telegram.InlineKeyboardButton{Text: messages.KEYBOARD_NEXT_PAGE, CallbackData: makeCallback(ReviewRequests, position + 1)})
+    }
+    keyBoard := [][]telegram.InlineKeyboardButton{
+        {
+            {Text: messages.KEYBOARD_APPROVE_REQUEST, CallbackData: makePairCallback(ApproveReviewRequest, requestId, position)},
+            {Text: messages.KEYBOARD_DECLINE_REQUEST, CallbackData: makePairCallback(DeclineReviewRequest, requestId, position)},
+            {Text: messages.KEYBOARD_SKIP_REQUEST, CallbackData: makeCallback(ReviewRequests, next)},
+        },
+    }
+    if len(pages) > 0 {
+        keyBoard = append(keyBoard, pages)
+    }
+    keyBoard = append(keyBoard, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_GET_BACK, CallbackData: GetBack}})
+    return telegram.InlineKeyboardMarkup{InlineKeyboard: keyBoard}
+}
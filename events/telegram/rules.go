@@ -0,0 +1,397 @@
+package telegram
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "log"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/events"
+    "user-handler-bot/helpers"
+    "user-handler-bot/messages"
+    "user-handler-bot/storage"
+)
+
+// rulesFile keeps rules which decline requests to join in addition to rules added from the bot.
+// The file looks like
+//  {"rules": [
+//      {"kind": "user_id", "value": "123456"},
+//      {"kind": "username", "value": "(?i)casino|crypto"},
+//      {"kind": "no_username"},
+//      {"kind": "language", "value": "fa"},
+//      {"kind": "repeated", "value": "24h"},
+//      {"kind": "banned"}
+//  ]}
+// Rules from the file can not be deleted from the bot
+const rulesFile = "rules.json"
+
+const (
+    JoinRules = "/join-rules"
+    // JoinRule is the prefix of buttons which change rules, AddJoinRule has the index of the kind
+    // and DeleteJoinRule has id of the rule
+    JoinRule = "/join-rule"
+    AddJoinRule = "/join-rule-add"
+    DeleteJoinRule = "/join-rule-delete"
+    JoinDecisions = "/join-decisions"
+)
+
+const joinDecisionsShowCount = 20
+
+// joinDecisionsRetention is how long requests to join are logged, the repeated rule can not look further back
+const joinDecisionsRetention = 90 * 24 * time.Hour
+
+// joinRuleKinds are in the order of buttons which add rules
+var joinRuleKinds = []string{
+    storage.JoinRuleUserId,
+    storage.JoinRuleUsername,
+    storage.JoinRuleFirstName,
+    storage.JoinRuleNoUsername,
+    storage.JoinRuleLanguage,
+    storage.JoinRuleRepeated,
+    storage.JoinRuleBanned,
+}
+
+type joinRule struct {
+    storage.JoinRule
+    pattern *regexp.Regexp
+    window  time.Duration
+}
+
+// newJoinRule checks the value of the rule and prepares it for matching
+func newJoinRule(rule storage.JoinRule) (joinRule, error) {
+    rule.Value = strings.TrimSpace(rule.Value)
+    result := joinRule{JoinRule: rule}
+    switch rule.Kind {
+    case storage.JoinRuleUserId:
+        if _, err := strconv.Atoi(rule.Value); err != nil {
+            return result, errors.New("user id must be a number")
+        }
+    case storage.JoinRuleUsername, storage.JoinRuleFirstName:
+        pattern, err := regexp.Compile(rule.Value)
+        if err != nil || rule.Value == "" {
+            return result, errors.New("wrong regular expression " + rule.Value)
+        }
+        result.pattern = pattern
+    case storage.JoinRuleLanguage:
+        if rule.Value == "" {
+            return result, errors.New("language code is empty")
+        }
+        result.Value = strings.ToLower(rule.Value)
+    case storage.JoinRuleRepeated:
+        window, err := time.ParseDuration(rule.Value)
+        if err != nil || window <= 0 {
+            return result, errors.New("wrong window " + rule.Value + ", use a duration like 24h")
+        }
+        if window > joinDecisionsRetention {
+            return result, errors.New("window " + rule.Value + " is longer than requests are logged: " + joinDecisionsRetention.String())
+        }
+        result.window = window
+    case storage.JoinRuleNoUsername, storage.JoinRuleBanned:
+        result.Value = ""
+    default:
+        return result, errors.New("unknown rule " + rule.Kind)
+    }
+    return result, nil
+}
+
+func loadJoinRules() []joinRule {
+    data, err := os.ReadFile(rulesFile)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        log.Fatal(helpers.WrapErr(err, "cant read " + rulesFile))
+    }
+    var file struct {
+        Rules []storage.JoinRule `json:"rules"`
+    }
+    if err := json.Unmarshal(data, &file); err != nil {
+        log.Fatal(helpers.WrapErr(err, "cant parse " + rulesFile))
+    }
+    var rules []joinRule
+    for _, item := range file.Rules {
+        rule, err := newJoinRule(item)
+        if err != nil {
+            log.Fatal(helpers.WrapErr(err, "wrong " + rulesFile))
+        }
+        rules = append(rules, rule)
+    }
+    return rules
+}
+
+// getJoinRules returns rules from the file and rules added from the bot
+func (h* Handler) getJoinRules() ([]joinRule, error) {
+    saved, err := h.storage.GetJoinRules(context.TODO())
+    if err != nil {
+        return nil, err
+    }
+    rules := append([]joinRule{}, h.fileRules...)
+    for _, item := range saved {
+        rule, err := newJoinRule(item)
+        if err != nil {
+            log.Println(helpers.WrapErr(err, "skip join rule " + strconv.Itoa(item.Id)))
+            continue
+        }
+        rules = append(rules, rule)
+    }
+    return rules, nil
+}
+
+// checkJoinRules declines the request when a rule matches it, every request is logged
+// so the next requests of the user can be checked against it
+func (h* Handler) checkJoinRules(event events.Event) (bool, error) {
+    request := event.Meta.(*telegram.ChatJoinRequest)
+    rules, err := h.getJoinRules()
+    if err != nil {
+        return false, err
+    }
+    for _, rule := range rules {
+        matched, err := h.matchJoinRule(rule, request)
+        if err != nil {
+            return false, err
+        }
+        if matched {
+            return true, h.declineByJoinRule(rule, request)
+        }
+    }
+    return false, h.logJoinDecision(request, storage.JoinDecisionPassed, "")
+}
+
+func (h* Handler) matchJoinRule(rule joinRule, request *telegram.ChatJoinRequest) (bool, error) {
+    switch rule.Kind {
+    case storage.JoinRuleUserId:
+        return strconv.Itoa(request.User.Id) == rule.Value, nil
+    case storage.JoinRuleUsername:
+        return request.User.Username != "" && rule.pattern.MatchString(request.User.Username), nil
+    case storage.JoinRuleFirstName:
+        return rule.pattern.MatchString(request.User.FirstName), nil
+    case storage.JoinRuleNoUsername:
+        return request.User.Username == "", nil
+    case storage.JoinRuleLanguage:
+        return strings.EqualFold(request.User.LanguageCode, rule.Value), nil
+    case storage.JoinRuleRepeated:
+        // the same request comes again when its processing failed, it is not a repeated one
+        count, err := h.storage.CountJoinDecisions(context.TODO(), request.User.Id, time.Now().Add(-rule.window), request.Date)
+        return count > 0, err
+    case storage.JoinRuleBanned:
+        // declined requests do not count, the user may have been declined by a deleted rule or have missed the captcha
+        member, err := h.client.GetChatMember(request.User.Id, request.Chat.Id)
+        if err != nil {
+            log.Println(helpers.WrapErr(err, "cant check the ban of userId: " + strconv.Itoa(request.User.Id)))
+            return false, nil
+        }
+        return member.Status == "kicked", nil
+    }
+    return false, nil
+}
+
+func (h* Handler) declineByJoinRule(rule joinRule, request *telegram.ChatJoinRequest) error {
+    log.Println("request to join of user " + strconv.Itoa(request.User.Id) + " is declined by the rule " + formatJoinRule(rule))
+    ok, err := h.client.DeclineChatJoinRequest(request.User.Id, request.Chat.Id)
+    if errors.Is(err, telegram.ErrJoinRequestMissing) {
+        return nil
+    }
+    if err != nil {
+        return helpers.WrapErr(err, "cant decline request to join by rule userId: " + strconv.Itoa(request.User.Id))
+    }
+    if !ok {
+        return nil
+    }
+    return h.logJoinDecision(request, storage.JoinDecisionDeclined, formatJoinRule(rule))
+}
+
+// DeleteOldJoinDecisions removes requests to join logged before the retention
+func (h* Handler) DeleteOldJoinDecisions() {
+    err := h.storage.DeleteJoinDecisionsBefore(context.TODO(), time.Now().Add(-joinDecisionsRetention))
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "Cant DeleteOldJoinDecisions"))
+    }
+}
+
+func (h* Handler) logJoinDecision(request *telegram.ChatJoinRequest, decision string, rule string) error {
+    return h.storage.LogJoinDecision(context.TODO(), storage.JoinDecision{
+        UserId: request.User.Id,
+        ChatId: request.Chat.Id,
+        Username: request.User.Username,
+        RequestDate: request.Date,
+        Decision: decision,
+        Rule: rule,
+    })
+}
+
+func formatJoinRule(rule joinRule) string {
+    if rule.Value == "" {
+        return rule.Kind
+    }
+    return rule.Kind + ": " + rule.Value
+}
+
+func joinRuleLabel(kind string) string {
+    switch kind {
+    case storage.JoinRuleUserId:
+        return messages.JOIN_RULE_USER_ID
+    case storage.JoinRuleUsername:
+        return messages.JOIN_RULE_USERNAME
+    case storage.JoinRuleFirstName:
+        return messages.JOIN_RULE_FIRST_NAME
+    case storage.JoinRuleNoUsername:
+        return messages.JOIN_RULE_NO_USERNAME
+    case storage.JoinRuleLanguage:
+        return messages.JOIN_RULE_LANGUAGE
+    case storage.JoinRuleRepeated:
+        return messages.JOIN_RULE_REPEATED
+    case storage.JoinRuleBanned:
+        return messages.JOIN_RULE_BANNED
+    }
+    return kind
+}
+
+// joinRuleHint asks for the value of the rule, an empty hint means the rule has no value
+func joinRuleHint(kind string) string {
+    switch kind {
+    case storage.JoinRuleUserId:
+        return messages.SET_JOIN_RULE_USER_ID
+    case storage.JoinRuleUsername, storage.JoinRuleFirstName:
+        return messages.SET_JOIN_RULE_PATTERN
+    case storage.JoinRuleLanguage:
+        return messages.SET_JOIN_RULE_LANGUAGE
+    case storage.JoinRuleRepeated:
+        return messages.SET_JOIN_RULE_WINDOW
+    }
+    return ""
+}
+
+func (h* Handler) answerJoinRuleCallback(chatId int, messageId int, action string, param int) error {
+    switch action {
+    case AddJoinRule:
+        if param < 0 || param >= len(joinRuleKinds) {
+            return nil
+        }
+        kind := joinRuleKinds[param]
+        if hint := joinRuleHint(kind); hint != "" {
+            h.waitRuleInput(kind)
+            return h.client.UpdateInlineKeyBoard(
+                h.makeHTMLInlineKeyBoard(chatId, messageId, telegram.BoldHTML(joinRuleLabel(kind)) + "\n" + telegram.EscapeHTML(hint), h.getBackToJoinRulesInlineKeyBoard()),
+            )
+        }
+        if _, err := h.storage.CreateJoinRule(context.TODO(), storage.JoinRule{Kind: kind}); err != nil {
+            return err
+        }
+        return h.sendJoinRules(chatId, messageId, messages.JOIN_RULE_ADDED)
+    case DeleteJoinRule:
+        if err := h.storage.DeleteJoinRule(context.TODO(), param); err != nil {
+            return err
+        }
+        return h.sendJoinRules(chatId, messageId, messages.JOIN_RULE_DELETED)
+    }
+    return h.client.SendMessage(chatId, "Command not found")
+}
+
+func (h* Handler) waitRuleInput(kind string) {
+    h.waitCampaignInput("", 0)
+    h.ruleInput = kind
+}
+
+// processRuleInput handles the admin message with the value of the rule he is adding
+func (h* Handler) processRuleInput(message *telegram.Message) error {
+    chatId := message.Chat.Id
+    kind := h.ruleInput
+    h.waitRuleInput("")
+
+    rule, err := newJoinRule(storage.JoinRule{Kind: kind, Value: message.Text})
+    if err != nil {
+        h.waitRuleInput(kind)
+        return h.client.SendInlineKeyBoard(h.makeInlineKeyBoard(
+            chatId,
+            0,
+            messages.JOIN_RULE_WRONG_VALUE + err.Error() + "\n\n" + joinRuleHint(kind),
+            h.getBackToJoinRulesInlineKeyBoard(),
+        ))
+    }
+    if _, err := h.storage.CreateJoinRule(context.TODO(), rule.JoinRule); err != nil {
+        return helpers.WrapErr(err, "cant create join rule from input")
+    }
+    return h.sendJoinRules(chatId, 0, messages.JOIN_RULE_ADDED)
+}
+
+// sendJoinRules edits the message with the list of rules, a new message is sent when messageId is 0
+func (h* Handler) sendJoinRules(chatId int, messageId int, notice string) error {
+    rules, err := h.getJoinRules()
+    if err != nil {
+        return helpers.WrapErr(err, "cant get join rules")
+    }
+    text := messages.JOIN_RULES
+    if notice != "" {
+        text = notice + "\n\n" + text
+    }
+    if len(rules) == 0 {
+        text += "\n" + messages.JOIN_RULES_EMPTY
+    }
+    var buttons [][]telegram.InlineKeyboardButton
+    for _, rule := range rules {
+        line := joinRuleLabel(rule.Kind)
+        if rule.Value != "" {
+            line += ": " + rule.Value
+        }
+        if rule.Id == 0 {
+            text += "\n• " + line + " " + messages.JOIN_RULE_FROM_FILE
+            continue
+        }
+        text += "\n• " + line
+        buttons = append(buttons, []telegram.InlineKeyboardButton{{
+            Text: messages.KEYBOARD_DELETE_JOIN_RULE + line,
+            CallbackData: makeCallback(DeleteJoinRule, rule.Id),
+        }})
+    }
+
+    var addButtons []telegram.InlineKeyboardButton
+    for i, kind := range joinRuleKinds {
+        addButtons = append(addButtons, telegram.InlineKeyboardButton{Text: "+ " + joinRuleLabel(kind), CallbackData: makeCallback(AddJoinRule, i)})
+    }
+    buttons = append(buttons, chunkButtons(addButtons, 2)...)
+    buttons = append(buttons,
+        []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_JOIN_DECISIONS, CallbackData: JoinDecisions}},
+        []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_GET_BACK, CallbackData: GetBack}},
+    )
+    keyBoard := h.makeInlineKeyBoard(chatId, messageId, text, telegram.InlineKeyboardMarkup{InlineKeyboard: buttons})
+    if messageId == 0 {
+        return h.client.SendInlineKeyBoard(keyBoard)
+    }
+    return h.client.UpdateInlineKeyBoard(keyBoard)
+}
+
+// sendJoinDecisions shows the last declined requests with the rule or the admin who declined them
+func (h* Handler) sendJoinDecisions(chatId int, messageId int) error {
+    decisions, err := h.storage.GetJoinDecisions(context.TODO(), storage.JoinDecisionDeclined, joinDecisionsShowCount)
+    if err != nil {
+        return helpers.WrapErr(err, "cant get join decisions")
+    }
+    text := telegram.BoldHTML(messages.JOIN_DECISIONS)
+    if len(decisions) == 0 {
+        text += "\n" + telegram.EscapeHTML(messages.JOIN_DECISIONS_EMPTY)
+    }
+    for _, decision := range decisions {
+        user := strconv.Itoa(decision.UserId)
+        if decision.Username != "" {
+            user = "@" + telegram.EscapeHTML(decision.Username) + " (" + user + ")"
+        }
+        text += "\n" + decision.CreatedAt.Format(LastMessageForAllFormat) + " " + user + " — " + telegram.EscapeHTML(decision.Rule)
+    }
+    return h.client.UpdateInlineKeyBoard(
+        h.makeHTMLInlineKeyBoard(chatId, messageId, text, h.getBackToJoinRulesInlineKeyBoard()),
+    )
+}
+
+func (h* Handler) getBackToJoinRulesInlineKeyBoard() telegram.InlineKeyboardMarkup {
+    return telegram.InlineKeyboardMarkup{
+        InlineKeyboard: [][]telegram.InlineKeyboardButton{
+        {
+            {Text: messages.KEYBOARD_GET_BACK, CallbackData: JoinRules},
+        },
+    },}
+}
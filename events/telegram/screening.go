@@ -0,0 +1,224 @@
+package telegram
+
+import (
+    "context"
+    "encoding/json"
+    "log"
+    "strconv"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/events"
+    "user-handler-bot/helpers"
+    "user-handler-bot/messages"
+    "user-handler-bot/storage"
+)
+
+// ScreeningAnswer has two parameters: the request and the question with the chosen option
+// as step * screeningOptionsLimit + option
+const ScreeningAnswer = "/screening-answer"
+
+// screeningOptionsLimit is more than options of any question, telegram shows at most 100 buttons in a keyboard
+const screeningOptionsLimit = 100
+
+// screeningDecider decides requests whose answers passed the questionnaire, its id is never a claim of an admin
+var screeningDecider = telegram.User{Id: -1, FirstName: messages.SCREENING_DECIDER}
+
+// startScreening saves the request for admins and asks the user the first question in the private chat
+func (h* Handler) startScreening(event events.Event) error {
+    request := event.Meta.(*telegram.ChatJoinRequest)
+    eventData, err := json.Marshal(event)
+    if err != nil {
+        return helpers.WrapErr(err, "startScreening: cant marshal event")
+    }
+    requestId, err := h.storage.SaveDelayedEventRequestToJoin(context.TODO(), eventData, 0, false)
+    if err != nil {
+        return err
+    }
+    userChatId := request.UserChatId
+    if userChatId == 0 {
+        // the private chat with a user has the same id as the user
+        userChatId = request.User.Id
+    }
+    screening := storage.Screening{
+        UserId: request.User.Id,
+        ChatId: request.Chat.Id,
+        UserChatId: userChatId,
+        RequestId: requestId,
+        Status: storage.ScreeningStatusAsking,
+        Answers: make(map[string]string),
+    }
+    if err := h.storage.SaveScreening(context.TODO(), screening); err != nil {
+        return err
+    }
+    if err := h.client.SendMessage(userChatId, messages.SCREENING_INTRO); err != nil {
+        // the user can not answer, admins decide the request without answers
+        log.Println(helpers.WrapErr(err, "cant start screening userId: " + strconv.Itoa(request.User.Id)))
+        screening.Status = storage.ScreeningStatusReview
+        if err := h.storage.SaveScreening(context.TODO(), screening); err != nil {
+            return err
+        }
+        if !h.isRequestCardsEnabled() {
+            return nil
+        }
+        return h.sendRequestCards(requestId, telegram.BoldHTML(messages.NEW_REQUEST_TO_JOIN) + "\n" + h.formatRequestToJoin(request, requestId))
+    }
+    return h.askScreeningQuestion(screening)
+}
+
+// processScreeningMessage takes a typed answer, false means the user has no questionnaire in progress
+func (h* Handler) processScreeningMessage(message *telegram.Message) (bool, error) {
+    screening, question, ok, err := h.getScreeningQuestion(message.From.Id)
+    if !ok || err != nil {
+        return ok, err
+    }
+    if len(question.Options) > 0 || message.Text == "" {
+        if err := h.client.SendMessage(screening.UserChatId, messages.SCREENING_ANSWER_REQUIRED); err != nil {
+            return true, err
+        }
+        return true, h.askScreeningQuestion(screening)
+    }
+    return true, h.saveScreeningAnswer(screening, question, message.Text)
+}
+
+// answerScreeningButton takes a chosen option, buttons of questions which were already answered are ignored
+func (h* Handler) answerScreeningButton(callback *telegram.CallbackQuery) error {
+    _, requestId, answerIndex, ok := parsePairCallback(callback.Data)
+    if !ok || !h.questionnaire.enabled() {
+        return nil
+    }
+    step, option := answerIndex / screeningOptionsLimit, answerIndex % screeningOptionsLimit
+    // the button may be left in the questionnaire of another request of the user
+    screening, found, err := h.storage.GetScreening(context.TODO(), requestId)
+    if err != nil || !found || screening.UserId != callback.User.Id || screening.Status != storage.ScreeningStatusAsking {
+        return err
+    }
+    screening, question, ok, err := h.getScreeningStepQuestion(screening)
+    if !ok || err != nil || step != screening.Step || option < 0 || option >= len(question.Options) {
+        return err
+    }
+    answer := question.Options[option]
+    noButtons := telegram.InlineKeyboardMarkup{InlineKeyboard: [][]telegram.InlineKeyboardButton{}}
+    err = h.client.EditMessageWithKeyBoard(h.makeHTMLInlineKeyBoard(
+        callback.Message.Chat.Id,
+        callback.Message.Id,
+        telegram.EscapeHTML(question.Text) + "\n\n" + telegram.BoldHTML(answer),
+        noButtons,
+    ))
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "cant mark the screening answer"))
+    }
+    return h.saveScreeningAnswer(screening, question, answer)
+}
+
+func (h* Handler) getScreeningQuestion(userId int) (storage.Screening, question, bool, error) {
+    if !h.questionnaire.enabled() {
+        return storage.Screening{}, question{}, false, nil
+    }
+    screening, found, err := h.storage.GetAskingScreening(context.TODO(), userId)
+    if err != nil || !found {
+        return screening, question{}, false, err
+    }
+    return h.getScreeningStepQuestion(screening)
+}
+
+// getScreeningStepQuestion returns the next question of the screening, false means the user has nothing to answer
+func (h* Handler) getScreeningStepQuestion(screening storage.Screening) (storage.Screening, question, bool, error) {
+    if _, pending, err := h.storage.GetRequestToJoin(context.TODO(), screening.RequestId); err != nil || !pending {
+        if err == nil {
+            screening.Status = storage.ScreeningStatusClosed
+            err = h.storage.SaveScreening(context.TODO(), screening)
+        }
+        return screening, question{}, false, err
+    }
+    if screening.Step >= len(h.questionnaire.Questions) {
+        // the questionnaire was shortened after the user started it
+        return screening, question{}, true, h.finishScreening(screening)
+    }
+    return screening, h.questionnaire.Questions[screening.Step], true, nil
+}
+
+func (h* Handler) saveScreeningAnswer(screening storage.Screening, question question, answer string) error {
+    screening.Answers[question.Id] = answer
+    screening.Step++
+    if screening.Step < len(h.questionnaire.Questions) {
+        if err := h.storage.SaveScreening(context.TODO(), screening); err != nil {
+            return err
+        }
+        return h.askScreeningQuestion(screening)
+    }
+    return h.finishScreening(screening)
+}
+
+func (h* Handler) askScreeningQuestion(screening storage.Screening) error {
+    question := h.questionnaire.Questions[screening.Step]
+    var buttons [][]telegram.InlineKeyboardButton
+    for i, option := range question.Options {
+        buttons = append(buttons, []telegram.InlineKeyboardButton{{
+            Text: option,
+            CallbackData: makePairCallback(ScreeningAnswer, screening.RequestId, screening.Step * screeningOptionsLimit + i),
+        }})
+    }
+    if buttons == nil {
+        buttons = [][]telegram.InlineKeyboardButton{}
+    }
+    _, err := h.client.SendMessageWithKeyBoard(h.makeInlineKeyBoard(
+        screening.UserChatId,
+        0,
+        question.Text,
+        telegram.InlineKeyboardMarkup{InlineKeyboard: buttons},
+    ))
+    return helpers.WrapErr(err, "cant ask screening question userId: " + strconv.Itoa(screening.UserId))
+}
+
+// finishScreening approves the request when the answers pass, otherwise admins get a card with the answers
+func (h* Handler) finishScreening(screening storage.Screening) error {
+    screening.Status = storage.ScreeningStatusReview
+    if h.questionnaire.passes(screening.Answers) {
+        screening.Status = storage.ScreeningStatusApproved
+    }
+    if err := h.storage.SaveScreening(context.TODO(), screening); err != nil {
+        return err
+    }
+    if err := h.client.SendMessage(screening.UserChatId, messages.SCREENING_FINISHED); err != nil {
+        log.Println(err)
+    }
+
+    if screening.Status == storage.ScreeningStatusApproved {
+        _, err := h.decideRequestToJoin(screeningDecider, true, screening.RequestId)
+        return err
+    }
+    request, found, err := h.storage.GetRequestToJoin(context.TODO(), screening.RequestId)
+    if err != nil || !found {
+        // admins have already decided the request
+        return err
+    }
+    event, err := parseRequestToJoinEvent(request.Event)
+    if err != nil {
+        return err
+    }
+    text := telegram.BoldHTML(messages.SCREENING_NEEDS_REVIEW) + "\n" + h.formatRequestToJoin(event.Meta.(*telegram.ChatJoinRequest), screening.RequestId)
+    return h.sendRequestCards(screening.RequestId, text)
+}
+
+// formatScreeningAnswers shows answers to the questionnaire of the request in the order of questions
+func (h* Handler) formatScreeningAnswers(requestId int) string {
+    screening, found, err := h.storage.GetScreening(context.TODO(), requestId)
+    if err != nil {
+        log.Println(err)
+    }
+    if !found || len(screening.Answers) == 0 {
+        return ""
+    }
+    text := "\n\n" + telegram.BoldHTML(messages.SCREENING_ANSWERS)
+    for _, question := range h.questionnaire.Questions {
+        answer, ok := screening.Answers[question.Id]
+        if !ok {
+            continue
+        }
+        mark := "✅"
+        if !question.accepts(answer) {
+            mark = "❌"
+        }
+        text += "\n" + mark + " " + telegram.EscapeHTML(question.Text) + "\n" + telegram.EscapeHTML(answer)
+    }
+    return text
+}
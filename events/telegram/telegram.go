@@ -3,14 +3,17 @@ package telegram
 import (
     "context"
     "encoding/json"
+    "errors"
     "fmt"
     "log"
     "os"
     "strconv"
+    "sync"
     "time"
     "user-handler-bot/clients/telegram"
     "user-handler-bot/events"
     "user-handler-bot/helpers"
+    "user-handler-bot/messages"
     "user-handler-bot/storage"
 )
 
@@ -22,10 +25,20 @@ type Handler struct {
     offset                  int
     autoAcceptRequestEnable bool
     nextSetSendMsg          string
+    campaignInput           string
+    campaignInputId         int
+    templateInput           string
+    templateInputId         int
     processSendingMessage   chan string
-    countRequests           int
     lastInlineKeyBoardId    int
-    setNewTimeForSentMessageToAll bool
+    progressMu              sync.Mutex
+    progressUpdatedAt       map[int]time.Time
+    lastMediaGroup          mediaGroup
+    chatTitlesMu            sync.Mutex
+    chatTitles              map[string]string
+    questionnaire           questionnaire
+    fileRules               []joinRule
+    ruleInput               string
 }
 
 type DelayedRequest struct {
@@ -40,8 +53,10 @@ func New(client *telegram.Client, storage storage.Storage) *Handler {
         storage: storage,
         autoAcceptRequestEnable: checkAutoAcceptRequestEnable(),
         nextSetSendMsg: "",
-        countRequests: 0,
-        setNewTimeForSentMessageToAll: false,
+        progressUpdatedAt: make(map[int]time.Time),
+        chatTitles: make(map[string]string),
+        questionnaire: loadQuestionnaire(),
+        fileRules: loadJoinRules(),
     }
 }
 
@@ -70,8 +85,8 @@ func checkAutoAcceptRequestEnable() bool {
     }
 }
 
-func (h* Handler) Fetch(limit int) ([]events.Event, error) {
-    updates, err := h.client.GetUpdate(h.offset, limit)
+func (h* Handler) Fetch(ctx context.Context, limit int, timeout int) ([]events.Event, error) {
+    updates, err := h.client.GetUpdate(ctx, h.offset, limit, timeout)
     if err != nil {
         return nil, helpers.WrapErr(err, "events Fetcher error get updates")
     }
@@ -87,6 +102,14 @@ func (h* Handler) Fetch(limit int) ([]events.Event, error) {
     return result, nil
 }
 
+func (h* Handler) Receive(data []byte) (events.Event, error) {
+    var update telegram.Update
+    if err := json.Unmarshal(data, &update); err != nil {
+        return events.Event{}, helpers.WrapErr(err, "Receive Unmarshal update error")
+    }
+    return makeEventFromUpdate(update), nil
+}
+
 func(h* Handler) FetchDelayedRequestsToJoin(autoAccept bool) ([]events.Event, error) {
     jsonEvents, err := h.storage.GetEventsWithDelayedMsgAfterRequestToJoin(context.TODO(), autoAccept)
     if err != nil {
@@ -105,6 +128,9 @@ func(h* Handler) FetchDelayedRequestsToJoin(autoAccept bool) ([]events.Event, er
             Meta: &telegram.ChatJoinRequest{
                 User: delayEvent.Meta.User,
                 Chat: delayEvent.Meta.Chat,
+                Bio: delayEvent.Meta.Bio,
+                UserChatId: delayEvent.Meta.UserChatId,
+                Date: delayEvent.Meta.Date,
             },
         })
     }
@@ -112,20 +138,6 @@ func(h* Handler) FetchDelayedRequestsToJoin(autoAccept bool) ([]events.Event, er
     return allEvents, nil
 }
 
-func(h* Handler) CheckDelayedMessageSendToAll() {
-    message, err := h.storage.GetMessageForSend(context.TODO(), storage.KeyAllMessage)
-    if message.MessageId <= 0 || message.TimeToSent.Unix() == 0 {
-        return
-    }
-    if err != nil {
-        log.Println(helpers.WrapErr(err, "Cant get message for CheckDelayedMessageSendToAll"))
-    }
-    err = h.sendMessageForAllUsers(message.FromChatId, message.MessageId)
-    if err != nil {
-        log.Println(helpers.WrapErr(err, "sendMessageForAllUsers from CheckDelayedMessageSendToAll"))
-    }
-}
-
 func(h* Handler) CheckLeavers() {
     users, err := h.storage.GetAllUsers(context.TODO())
     if err != nil {
@@ -171,28 +183,55 @@ func (h* Handler) processCallBack(event events.Event) error {
 }
 
 func (h* Handler) processRequestToJoin(event events.Event) error {
-    delay, _ := h.storage.GetDelays(context.TODO(), storage.KeyDelayReqeustToJoin)
+    if declined, err := h.checkJoinRules(event); declined || err != nil {
+        return err
+    }
+    if h.questionnaire.enabled() {
+        return h.startScreening(event)
+    }
     if h.autoAcceptRequestEnable {
-        ok, err := h.saveUsersIntoDbAndApproveRequestToJoin(event)
-        if err != nil {
-            return err
-        }
-        if ok && delay == 0 {
-            return h.SentMessageToUserAfterAcceptRequestJoin(event)
+        if deadline := h.getCaptchaDeadline(); deadline > 0 {
+            return h.startCaptcha(event, deadline)
         }
+        return h.approveRequestToJoin(event, "auto-accept")
     }
 
-    if delay > 0 || !h.autoAcceptRequestEnable {
-        return h.SaveDelayedRequestsToJoin(event, delay)
+    delay, _ := h.storage.GetDelays(context.TODO(), storage.KeyDelayReqeustToJoin)
+    requestId, err := h.SaveDelayedRequestsToJoin(event, delay)
+    if err != nil || !h.isRequestCardsEnabled() {
+        return err
     }
+    text := telegram.BoldHTML(messages.NEW_REQUEST_TO_JOIN) + "\n" + h.formatRequestToJoin(event.Meta.(*telegram.ChatJoinRequest), requestId)
+    return h.sendRequestCards(requestId, text)
+}
 
+// approveRequestToJoin approves the request, the welcome message is sent now or after the acceptance delay,
+// source is logged as what approved the request
+func (h* Handler) approveRequestToJoin(event events.Event, source string) error {
+    delay, _ := h.storage.GetDelays(context.TODO(), storage.KeyDelayReqeustToJoin)
+    ok, err := h.saveUsersIntoDbAndApproveRequestToJoin(event)
+    if err != nil {
+        return err
+    }
+    if ok {
+        if err := h.logJoinDecision(event.Meta.(*telegram.ChatJoinRequest), storage.JoinDecisionApproved, source); err != nil {
+            log.Println(err)
+        }
+    }
+    if delay > 0 {
+        _, err := h.SaveDelayedRequestsToJoin(event, delay)
+        return err
+    }
+    if ok {
+        return h.SentMessageToUserAfterAcceptRequestJoin(event)
+    }
     return nil
 }
 
-func (h* Handler) SaveDelayedRequestsToJoin(event events.Event, delay int) error {
+func (h* Handler) SaveDelayedRequestsToJoin(event events.Event, delay int) (int, error) {
     eventData, err := json.Marshal(event)
     if err != nil {
-        return helpers.WrapErr(err, "SaveDelayedRequestsToJoin: cant marshal event")
+        return 0, helpers.WrapErr(err, "SaveDelayedRequestsToJoin: cant marshal event")
     }
     return h.storage.SaveDelayedEventRequestToJoin(context.TODO(), eventData, delay, h.autoAcceptRequestEnable)
 }
@@ -217,6 +256,9 @@ func (h* Handler) saveUsersIntoDbAndApproveRequestToJoin(event events.Event) (bo
         }
 
         ok, err := h.client.ApproveChatJoinRequest(userId, event.Meta.(*telegram.ChatJoinRequest).Chat.Id)
+        if errors.Is(err, telegram.ErrJoinRequestMissing) {
+            return false, nil
+        }
         if err != nil {
             return ok, helpers.WrapErr(
                 err,
@@ -239,6 +281,10 @@ func (h* Handler) saveUsersIntoDbAndApproveRequestToJoin(event events.Event) (bo
     }
 
     ok, err := h.client.ApproveChatJoinRequest(userId, event.Meta.(*telegram.ChatJoinRequest).Chat.Id)
+    if errors.Is(err, telegram.ErrJoinRequestMissing) {
+        // the request was already handled by another admin
+        return false, nil
+    }
     if err != nil {
         return ok, helpers.WrapErr(
             err,
@@ -249,10 +295,6 @@ func (h* Handler) saveUsersIntoDbAndApproveRequestToJoin(event events.Event) (bo
 }
 
 func (h* Handler) SentMessageToUserAfterAcceptRequestJoin(event events.Event) error {
-    if h.countRequests % 20 == 0 && h.countRequests != 0 {
-        // telegram limits
-        time.Sleep(2 * time.Second)
-    }
     userId := event.Meta.(*telegram.ChatJoinRequest).User.Id
     message, err := h.storage.GetCurrentMessage(context.TODO(), storage.KeyRequestMessage)
     userExists, err := h.storage.IsUserExists(context.TODO(), userId)
@@ -269,12 +311,12 @@ func (h* Handler) SentMessageToUserAfterAcceptRequestJoin(event events.Event) er
     if len(user.ChannelsIds) > 1 {
         return nil
     }
-    h.countRequests ++
-
+    ttl, _ := h.storage.GetDelays(context.TODO(), storage.KeyRequestMessageTTL)
     err = helpers.WrapErr(
-        h.client.ForwardMessage(
-            userId, message.FromChatId, message.MessageId), "cant send msg user:" +  strconv.Itoa(userId),
-        )
+        h.enqueueMessage(
+            userId, message, time.Duration(ttl) * time.Second, telegram.PriorityWelcome, storage.OutboxSourceWelcome, message.MessageId,
+        ), "cant enqueue msg user:" +  strconv.Itoa(userId),
+    )
     if err != nil {
         return err
     }
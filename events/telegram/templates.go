@@ -0,0 +1,302 @@
+package telegram
+
+import (
+    "context"
+    "log"
+    "strings"
+    "user-handler-bot/clients/telegram"
+    "user-handler-bot/helpers"
+    "user-handler-bot/messages"
+    "user-handler-bot/storage"
+)
+
+const (
+    Templates = "/templates"
+    NewTemplate = "/new-template"
+    ShowTemplate = "/template"
+    PreviewTemplate = "/template-preview"
+    RenameTemplate = "/template-rename"
+    SetTemplateMessage = "/template-set-message"
+    DeleteTemplate = "/template-delete"
+    NewCampaignFromTemplate = "/template-new-campaign"
+    UseTemplateForRequest = "/template-use-request"
+    // UseTemplateForCampaign has two parameters: the template and the campaign
+    UseTemplateForCampaign = "/template-use-campaign"
+)
+
+const (
+    templateInputName = "name"
+    templateInputMessage = "message"
+
+    templatesShowCount = 30
+)
+
+func (h* Handler) answerTemplateCallback(chatId int, messageId int, command string, templateId int) error {
+    template, err := h.storage.GetTemplate(context.TODO(), templateId)
+    if err != nil {
+        log.Println(err)
+        return h.sendTemplates(chatId, messageId, messages.TEMPLATE_NOT_FOUND)
+    }
+
+    switch command {
+    case ShowTemplate:
+        return h.client.UpdateInlineKeyBoard(
+            h.makeHTMLInlineKeyBoard(chatId, messageId, formatTemplate(template), h.getTemplateInlineKeyBoard(template.Id)),
+        )
+    case PreviewTemplate:
+        if _, err := h.sendStoredMessage(chatId, templateMessage(template), telegram.PriorityAdmin); err != nil {
+            return err
+        }
+        return h.client.SendInlineKeyBoard(
+            h.makeHTMLInlineKeyBoard(chatId, messageId, formatTemplate(template), h.getTemplateInlineKeyBoard(template.Id)),
+        )
+    case RenameTemplate:
+        h.waitTemplateInput(templateInputName, template.Id)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_TEMPLATE_NAME, h.getBackToTemplateInlineKeyBoard(template.Id)),
+        )
+    case SetTemplateMessage:
+        h.waitTemplateInput(templateInputMessage, template.Id)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_TEMPLATE_MESSAGE + "\n\n" + messages.PLACEHOLDERS_HINT, h.getBackToTemplateInlineKeyBoard(template.Id)),
+        )
+    case DeleteTemplate:
+        if err := h.storage.DeleteTemplate(context.TODO(), template.Id); err != nil {
+            return err
+        }
+        return h.sendTemplates(chatId, messageId, messages.TEMPLATE_DELETED)
+    }
+
+    if template.MessageId <= 0 {
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.TEMPLATE_HAS_NO_MESSAGE, h.getTemplateInlineKeyBoard(template.Id)),
+        )
+    }
+
+    switch command {
+    case NewCampaignFromTemplate:
+        campaign := storage.Campaign{
+            Name: template.Name,
+            FromChatId: template.FromChatId,
+            MessageId: template.MessageId,
+            Content: template.Content,
+            Status: storage.CampaignStatusDraft,
+            // callbacks come from private chats of admins, so the chat is the admin
+            CreatedBy: chatId,
+        }
+        if campaign.Id, err = h.storage.CreateCampaign(context.TODO(), campaign); err != nil {
+            return helpers.WrapErr(err, "cant create campaign from template")
+        }
+        return h.client.UpdateInlineKeyBoard(
+            h.makeHTMLInlineKeyBoard(chatId, messageId, formatCampaign(campaign), h.getCampaignInlineKeyBoard(campaign)),
+        )
+    case UseTemplateForRequest:
+        h.nextSetSendMsg = ""
+        err := h.storage.SaveMessage(context.TODO(), template.MessageId, template.FromChatId, template.Content, storage.KeyRequestMessage)
+        if err != nil {
+            return helpers.WrapErr(err, "cant use template for request to join")
+        }
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.SET_MESSAGE_TO_SEND_UPDATED, h.getBaseInlineKeyBoard()),
+        )
+    default:
+        return h.client.SendMessage(chatId, "Command not found")
+    }
+}
+
+// useTemplateForCampaign sets the template message to the campaign like the admin sent it
+func (h* Handler) useTemplateForCampaign(chatId int, messageId int, command string) error {
+    _, templateId, campaignId, ok := parsePairCallback(command)
+    if !ok {
+        return h.client.SendMessage(chatId, "Command not found")
+    }
+    h.waitCampaignInput("", 0)
+    campaign, err := h.storage.GetCampaign(context.TODO(), campaignId)
+    if err != nil {
+        log.Println(err)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.CAMPAIGN_NOT_FOUND, h.getCampaignsInlineKeyBoard()),
+        )
+    }
+    template, err := h.storage.GetTemplate(context.TODO(), templateId)
+    if err != nil || template.MessageId <= 0 {
+        log.Println(err)
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.TEMPLATE_HAS_NO_MESSAGE, h.getCampaignInlineKeyBoard(campaign)),
+        )
+    }
+    if !isCampaignEditable(campaign) {
+        return h.client.UpdateInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, messageId, messages.CAMPAIGN_CANT_BE_CHANGED, h.getCampaignInlineKeyBoard(campaign)),
+        )
+    }
+
+    campaign.FromChatId = template.FromChatId
+    campaign.MessageId = template.MessageId
+    campaign.Content = template.Content
+    requireCampaignConfirmation(&campaign)
+    if err := h.storage.UpdateCampaign(context.TODO(), campaign); err != nil {
+        return helpers.WrapErr(err, "cant update campaign from template")
+    }
+    if campaign.Status == storage.CampaignStatusAwaitingConfirmation {
+        return h.sendCampaignPreview(campaign)
+    }
+    return h.client.UpdateInlineKeyBoard(
+        h.makeHTMLInlineKeyBoard(chatId, messageId, formatCampaign(campaign), h.getCampaignInlineKeyBoard(campaign)),
+    )
+}
+
+func (h* Handler) waitTemplateInput(input string, templateId int) {
+    h.waitCampaignInput("", 0)
+    h.templateInput = input
+    h.templateInputId = templateId
+}
+
+// processTemplateInput handles the admin message after he pressed a template button which asks for input,
+// a new template gets its name first and its message next
+func (h* Handler) processTemplateInput(message *telegram.Message) error {
+    chatId := message.Chat.Id
+    input := h.templateInput
+    templateId := h.templateInputId
+    h.waitTemplateInput("", 0)
+
+    if input == templateInputName && templateId == 0 {
+        id, err := h.storage.CreateTemplate(context.TODO(), storage.Template{Name: strings.TrimSpace(message.Text)})
+        if err != nil {
+            return helpers.WrapErr(err, "cant create template")
+        }
+        h.waitTemplateInput(templateInputMessage, id)
+        return h.client.SendInlineKeyBoard(
+            h.makeInlineKeyBoard(chatId, 0, messages.SET_TEMPLATE_MESSAGE + "\n\n" + messages.PLACEHOLDERS_HINT, h.getBackToTemplateInlineKeyBoard(id)),
+        )
+    }
+
+    template, err := h.storage.GetTemplate(context.TODO(), templateId)
+    if err != nil {
+        return helpers.WrapErr(err, "cant get template for input")
+    }
+    switch input {
+    case templateInputName:
+        template.Name = strings.TrimSpace(message.Text)
+    case templateInputMessage:
+        template.FromChatId = chatId
+        template.MessageId = message.Id
+        template.Content = captureContent(message)
+        h.rememberMediaGroup(message, mediaGroup{templateId: template.Id})
+    }
+    if err := h.storage.UpdateTemplate(context.TODO(), template); err != nil {
+        return helpers.WrapErr(err, "cant update template from input")
+    }
+    return h.client.SendInlineKeyBoard(
+        h.makeHTMLInlineKeyBoard(chatId, 0, formatTemplate(template), h.getTemplateInlineKeyBoard(template.Id)),
+    )
+}
+
+func (h* Handler) sendTemplates(chatId int, messageId int, text string) error {
+    templates, err := h.storage.GetTemplates(context.TODO(), templatesShowCount)
+    if err != nil {
+        return helpers.WrapErr(err, "cant get templates")
+    }
+    var buttons [][]telegram.InlineKeyboardButton
+    for _, template := range templates {
+        buttons = append(buttons, []telegram.InlineKeyboardButton{{
+            Text: template.Name,
+            CallbackData: makeCallback(ShowTemplate, template.Id),
+        }})
+    }
+    buttons = append(buttons, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_NEW_TEMPLATE, CallbackData: NewTemplate}})
+    buttons = append(buttons, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_GET_BACK, CallbackData: GetBack}})
+
+    return h.client.UpdateInlineKeyBoard(
+        h.makeInlineKeyBoard(chatId, messageId, text, telegram.InlineKeyboardMarkup{InlineKeyboard: buttons}),
+    )
+}
+
+// getTemplatePickerButtons lists templates with messages, makeCallbackData makes the command for the picked one
+func (h* Handler) getTemplatePickerButtons(makeCallbackData func(templateId int) string) [][]telegram.InlineKeyboardButton {
+    templates, err := h.storage.GetTemplates(context.TODO(), templatesShowCount)
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "cant get templates to pick"))
+        return nil
+    }
+    var buttons [][]telegram.InlineKeyboardButton
+    for _, template := range templates {
+        if template.MessageId <= 0 {
+            continue
+        }
+        buttons = append(buttons, []telegram.InlineKeyboardButton{{
+            Text: messages.KEYBOARD_PICK_TEMPLATE + template.Name,
+            CallbackData: makeCallbackData(template.Id),
+        }})
+    }
+    return buttons
+}
+
+func (h* Handler) getRequestMessageTemplatesInlineKeyBoard() telegram.InlineKeyboardMarkup {
+    buttons := h.getTemplatePickerButtons(func(templateId int) string {
+        return makeCallback(UseTemplateForRequest, templateId)
+    })
+    buttons = append(buttons, []telegram.InlineKeyboardButton{{Text: messages.KEYBOARD_GET_BACK, CallbackData: GetBack}})
+    return telegram.InlineKeyboardMarkup{InlineKeyboard: buttons}
+}
+
+func (h* Handler) getCampaignTemplatesInlineKeyBoard(campaignId int) telegram.InlineKeyboardMarkup {
+    buttons := h.getTemplatePickerButtons(func(templateId int) string {
+        return makePairCallback(UseTemplateForCampaign, templateId, campaignId)
+    })
+    buttons = append(buttons, []telegram.InlineKeyboardButton{
+        {Text: messages.KEYBOARD_BACK_TO_CAMPAIGN, CallbackData: makeCallback(ShowCampaign, campaignId)},
+    })
+    return telegram.InlineKeyboardMarkup{InlineKeyboard: buttons}
+}
+
+func templateMessage(template storage.Template) storage.ForwardMessage {
+    return storage.ForwardMessage{
+        FromChatId: template.FromChatId,
+        MessageId: template.MessageId,
+        Content: template.Content,
+    }
+}
+
+// formatTemplate shows the template as HTML
+func formatTemplate(template storage.Template) string {
+    text := messages.TEMPLATE_CREATED + template.CreatedAt.Format(LastMessageForAllFormat)
+    if template.MessageId <= 0 {
+        text += "\n" + messages.TEMPLATE_HAS_NO_MESSAGE
+    } else if template.Content == "" {
+        text += "\n" + messages.TEMPLATE_COPIED_FROM_CHAT
+    }
+    return telegram.EscapeHTML(messages.TEMPLATE) + telegram.BoldHTML(template.Name) + "\n" + telegram.EscapeHTML(text)
+}
+
+func (h* Handler) getTemplateInlineKeyBoard(templateId int) telegram.InlineKeyboardMarkup {
+    return telegram.InlineKeyboardMarkup{
+        InlineKeyboard: [][]telegram.InlineKeyboardButton{
+        {
+            {Text: messages.KEYBOARD_PREVIEW_TEMPLATE, CallbackData: makeCallback(PreviewTemplate, templateId)},
+            {Text: messages.KEYBOARD_SET_TEMPLATE_MESSAGE, CallbackData: makeCallback(SetTemplateMessage, templateId)},
+        },
+        {
+            {Text: messages.KEYBOARD_RENAME_TEMPLATE, CallbackData: makeCallback(RenameTemplate, templateId)},
+            {Text: messages.KEYBOARD_DELETE_TEMPLATE, CallbackData: makeCallback(DeleteTemplate, templateId)},
+        },
+        {
+            {Text: messages.KEYBOARD_NEW_CAMPAIGN_FROM_TEMPLATE, CallbackData: makeCallback(NewCampaignFromTemplate, templateId)},
+        },
+        {
+            {Text: messages.KEYBOARD_USE_TEMPLATE_FOR_REQUEST, CallbackData: makeCallback(UseTemplateForRequest, templateId)},
+        },
+        {
+            {Text: messages.KEYBOARD_BACK_TO_TEMPLATES, CallbackData: Templates},
+        },
+    },}
+}
+
+func (h* Handler) getBackToTemplateInlineKeyBoard(templateId int) telegram.InlineKeyboardMarkup {
+    return telegram.InlineKeyboardMarkup{
+        InlineKeyboard: [][]telegram.InlineKeyboardButton{
+        {
+            {Text: messages.KEYBOARD_BACK_TO_TEMPLATE, CallbackData: makeCallback(ShowTemplate, templateId)},
+        },
+    },}
+}
@@ -0,0 +1,142 @@
+package helpers
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Schedule is a cron-like schedule "minute hour day-of-month month day-of-week",
+// fields support *, numbers, lists 1,15, ranges 1-5 and steps */2 or 1-10/3
+type Schedule struct {
+    minutes     map[int]bool
+    hours       map[int]bool
+    daysOfMonth map[int]bool
+    months      map[int]bool
+    daysOfWeek  map[int]bool
+    anyDayOfMonth bool
+    anyDayOfWeek  bool
+}
+
+var scheduleShortcuts = map[string]string{
+    "@hourly": "0 * * * *",
+    "@daily": "0 0 * * *",
+    "@weekly": "0 0 * * 0",
+    "@monthly": "0 0 1 * *",
+}
+
+// how far Next looks for a matching time, schedules like 30 february never match
+const scheduleSearchLimit = 5 * 366 * 24 * time.Hour
+
+func ParseSchedule(spec string) (Schedule, error) {
+    spec = strings.TrimSpace(spec)
+    if shortcut, ok := scheduleShortcuts[spec]; ok {
+        spec = shortcut
+    }
+    fields := strings.Fields(spec)
+    if len(fields) != 5 {
+        return Schedule{}, errors.New("schedule must have 5 fields: minute hour day month weekday")
+    }
+
+    var schedule Schedule
+    var err error
+    if schedule.minutes, err = parseScheduleField(fields[0], 0, 59); err != nil {
+        return Schedule{}, WrapErr(err, "minute")
+    }
+    if schedule.hours, err = parseScheduleField(fields[1], 0, 23); err != nil {
+        return Schedule{}, WrapErr(err, "hour")
+    }
+    if schedule.daysOfMonth, err = parseScheduleField(fields[2], 1, 31); err != nil {
+        return Schedule{}, WrapErr(err, "day of month")
+    }
+    if schedule.months, err = parseScheduleField(fields[3], 1, 12); err != nil {
+        return Schedule{}, WrapErr(err, "month")
+    }
+    // 7 is sunday too like in cron
+    if schedule.daysOfWeek, err = parseScheduleField(fields[4], 0, 7); err != nil {
+        return Schedule{}, WrapErr(err, "day of week")
+    }
+    if schedule.daysOfWeek[7] {
+        schedule.daysOfWeek[0] = true
+    }
+    // a step like */2 still counts as any day for matchDay like in cron
+    schedule.anyDayOfMonth = strings.HasPrefix(fields[2], "*")
+    schedule.anyDayOfWeek = strings.HasPrefix(fields[4], "*")
+    return schedule, nil
+}
+
+// Next returns the first time after the given one which matches the schedule, zero time if there is no such time
+func (s Schedule) Next(after time.Time) time.Time {
+    t := after.Truncate(time.Minute).Add(time.Minute)
+    limit := t.Add(scheduleSearchLimit)
+    for t.Before(limit) {
+        if !s.months[int(t.Month())] {
+            t = time.Date(t.Year(), t.Month() + 1, 1, 0, 0, 0, 0, t.Location())
+            continue
+        }
+        if !s.matchDay(t) {
+            t = time.Date(t.Year(), t.Month(), t.Day() + 1, 0, 0, 0, 0, t.Location())
+            continue
+        }
+        if !s.hours[t.Hour()] {
+            t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour() + 1, 0, 0, 0, t.Location())
+            continue
+        }
+        if !s.minutes[t.Minute()] {
+            t = t.Add(time.Minute)
+            continue
+        }
+        return t
+    }
+    return time.Time{}
+}
+
+// matchDay follows cron: when both day fields are set the day matches any of them
+func (s Schedule) matchDay(t time.Time) bool {
+    dayOfMonth := s.daysOfMonth[t.Day()]
+    dayOfWeek := s.daysOfWeek[int(t.Weekday())]
+    if s.anyDayOfMonth || s.anyDayOfWeek {
+        return dayOfMonth && dayOfWeek
+    }
+    return dayOfMonth || dayOfWeek
+}
+
+func parseScheduleField(field string, min int, max int) (map[int]bool, error) {
+    values := map[int]bool{}
+    for _, part := range strings.Split(field, ",") {
+        step := 1
+        if i := strings.Index(part, "/"); i >= 0 {
+            var err error
+            step, err = strconv.Atoi(part[i+1:])
+            if err != nil || step <= 0 {
+                return nil, errors.New("wrong step in " + part)
+            }
+            part = part[:i]
+        }
+
+        from, to := min, max
+        if part != "*" {
+            bounds := strings.SplitN(part, "-", 2)
+            var err error
+            if from, err = strconv.Atoi(bounds[0]); err != nil {
+                return nil, errors.New("wrong value " + part)
+            }
+            to = from
+            if len(bounds) == 2 {
+                if to, err = strconv.Atoi(bounds[1]); err != nil {
+                    return nil, errors.New("wrong value " + part)
+                }
+            } else if step > 1 {
+                to = max
+            }
+        }
+        if from < min || to > max || from > to {
+            return nil, errors.New("value out of range " + strconv.Itoa(min) + "-" + strconv.Itoa(max) + " in " + part)
+        }
+        for value := from; value <= to; value += step {
+            values[value] = true
+        }
+    }
+    return values, nil
+}
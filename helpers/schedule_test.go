@@ -0,0 +1,44 @@
+package helpers
+
+import (
+    "testing"
+    "time"
+)
+
+func TestScheduleNext(t *testing.T) {
+    // 2024-01-01 is monday
+    after := time.Date(2024, 1, 1, 10, 7, 0, 0, time.UTC)
+    tests := []struct {
+        spec string
+        want time.Time
+    }{
+        {"*/15 * * * *", time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)},
+        {"30 9 * * *", time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)},
+        {"@daily", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+        {"0 0 */2 * *", time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+        // a step in one day field restricts days together with the other field
+        {"0 0 */2 * 1", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+        {"0 0 1 * */2", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+        // both day fields set, any of them matches
+        {"0 0 15 * 5", time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)},
+        {"0 12 * * 7", time.Date(2024, 1, 7, 12, 0, 0, 0, time.UTC)},
+        {"0 0 30 2 *", time.Time{}},
+    }
+    for _, test := range tests {
+        schedule, err := ParseSchedule(test.spec)
+        if err != nil {
+            t.Fatalf("%q: %v", test.spec, err)
+        }
+        if got := schedule.Next(after); !got.Equal(test.want) {
+            t.Errorf("%q: Next = %v, want %v", test.spec, got, test.want)
+        }
+    }
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+    for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+        if _, err := ParseSchedule(spec); err == nil {
+            t.Errorf("%q: expected an error", spec)
+        }
+    }
+}
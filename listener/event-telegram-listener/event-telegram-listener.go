@@ -1,6 +1,7 @@
 package event_telegram_listener
 
 import (
+    "context"
     "log"
     "time"
     "user-handler-bot/events"
@@ -11,32 +12,36 @@ type Listener struct {
     fetcher     events.Fetcher
     processor   events.Processor
     eventLimit  int
+    pollTimeout int
     lostEvents  []events.Event
 }
 
-func New (fetcher events.Fetcher, processor events.Processor, eventLimit int) Listener {
+func New (fetcher events.Fetcher, processor events.Processor, eventLimit int, pollTimeout int) Listener {
     return Listener{
         fetcher: fetcher,
         processor: processor,
         eventLimit: eventLimit,
+        pollTimeout: pollTimeout,
     }
 }
 
-func (l *Listener) Start() error {
+func (l *Listener) Start(ctx context.Context) error {
     log.Println("App started")
-    //handle delayed request_to_join
-    go l.processDelayedSentMsgAfterRequestToJoin()
-    go l.processSendMessageToAllUsers()
-    go l.processCheckLeavers()
+    l.startBackgroundProcesses()
     for {
-        events, err := l.fetcher.Fetch(l.eventLimit)
+        // telegram holds getUpdates up to pollTimeout seconds until new updates come
+        events, err := l.fetcher.Fetch(ctx, l.eventLimit, l.pollTimeout)
+        if ctx.Err() != nil {
+            log.Println("App stopped")
+            return nil
+        }
         if err != nil {
             log.Println(helpers.WrapErr(err, "Cant fetch events in Start() from listener"))
+            time.Sleep(3 * time.Second)
             continue
         }
 
         if len(events) == 0 {
-            time.Sleep(3 * time.Second)
             continue
         }
 
@@ -45,20 +50,33 @@ func (l *Listener) Start() error {
             continue
         }
 
+        l.handleLostEvents()
+    }
+}
 
-        // handle lost events:
-        if  len(l.lostEvents) != 0 && len(l.lostEvents) % 30 == 0 {
-            err := l.handleEvents(l.lostEvents)
-            if err != nil {
-                log.Println(helpers.WrapErr(err, "Cant handleEvents lost events from Start"))
-            }
-        }
-        if len(l.lostEvents) == 100 {
-            log.Println("many unhandled events")
-            l.lostEvents = nil
-            //TODO: mb save
+func (l *Listener) startBackgroundProcesses() {
+    //handle delayed request_to_join
+    go l.processDelayedSentMsgAfterRequestToJoin()
+    go l.processSendMessageToAllUsers()
+    go l.processRecurringBroadcasts()
+    go l.processCheckLeavers()
+    go l.processOutbox()
+    go l.processExpiredCaptchas()
+    go l.processOldJoinDecisions()
+}
+
+func (l *Listener) handleLostEvents() {
+    if  len(l.lostEvents) != 0 && len(l.lostEvents) % 30 == 0 {
+        err := l.handleEvents(l.lostEvents)
+        if err != nil {
+            log.Println(helpers.WrapErr(err, "Cant handleEvents lost events"))
         }
     }
+    if len(l.lostEvents) == 100 {
+        log.Println("many unhandled events")
+        l.lostEvents = nil
+        //TODO: mb save
+    }
 }
 
 func (l *Listener) processDelayedSentMsgAfterRequestToJoin() {
@@ -85,9 +103,17 @@ func (l *Listener) processSendMessageToAllUsers() {
     log.Println("start CheckDelayedMessageSendToAll")
     for {
         l.fetcher.CheckDelayedMessageSendToAll()
+        time.Sleep(3 * time.Second)
     }
 }
 
+func (l *Listener) processRecurringBroadcasts() {
+    log.Println("start processRecurringBroadcasts")
+    for {
+        l.fetcher.CheckRecurringBroadcasts()
+        time.Sleep(10 * time.Second)
+    }
+}
 
 func (l *Listener) processCheckLeavers() {
     log.Println("start procesCheckLeavers")
@@ -98,6 +124,31 @@ func (l *Listener) processCheckLeavers() {
     }
 }
 
+func (l *Listener) processExpiredCaptchas() {
+    log.Println("start processExpiredCaptchas")
+    for {
+        l.fetcher.CheckExpiredCaptchas()
+        time.Sleep(5 * time.Second)
+    }
+}
+
+func (l *Listener) processOldJoinDecisions() {
+    log.Println("start processOldJoinDecisions")
+    for {
+        l.fetcher.DeleteOldJoinDecisions()
+        time.Sleep(time.Hour)
+    }
+}
+
+func (l *Listener) processOutbox() {
+    log.Println("start processOutbox")
+    l.fetcher.RecoverOutbox()
+    for {
+        if l.fetcher.DrainOutbox() == 0 {
+            time.Sleep(time.Second)
+        }
+    }
+}
 
 func (l *Listener) handleEvents(gotEvents []events.Event) error {
     for _, event := range gotEvents {
@@ -0,0 +1,121 @@
+package event_telegram_listener
+
+import (
+    "context"
+    "crypto/subtle"
+    "io"
+    "log"
+    "net/http"
+    "time"
+    "user-handler-bot/events"
+    "user-handler-bot/helpers"
+)
+
+const (
+    secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+    maxUpdateSize     = 1 << 20
+    updatesQueueSize  = 100
+    shutdownTimeout   = 10 * time.Second
+    // queueTimeout is how long an update waits for the full queue before telegram is asked to retry it
+    queueTimeout      = 5 * time.Second
+)
+
+type WebhookListener struct {
+    Listener
+    receiver     events.Receiver
+    addr         string
+    path         string
+    secretToken  string
+    updates      chan events.Event
+}
+
+func NewWebhook(
+    fetcher events.Fetcher,
+    processor events.Processor,
+    receiver events.Receiver,
+    addr string,
+    path string,
+    secretToken string,
+) WebhookListener {
+    return WebhookListener{
+        Listener: New(fetcher, processor, 0, 0),
+        receiver: receiver,
+        addr: addr,
+        path: path,
+        secretToken: secretToken,
+        updates: make(chan events.Event, updatesQueueSize),
+    }
+}
+
+func (l *WebhookListener) Start(ctx context.Context) error {
+    log.Println("App started in webhook mode on " + l.addr + l.path)
+    l.startBackgroundProcesses()
+    // updates are handled one by one like in polling mode, handler is not safe for concurrent use
+    go l.processUpdates()
+
+    mux := http.NewServeMux()
+    mux.HandleFunc(l.path, l.serveUpdate)
+    server := &http.Server{Addr: l.addr, Handler: mux}
+    go func() {
+        <-ctx.Done()
+        shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+        defer cancel()
+        if err := server.Shutdown(shutdownCtx); err != nil {
+            log.Println(helpers.WrapErr(err, "cant shutdown webhook server"))
+        }
+    }()
+
+    err := server.ListenAndServe()
+    if err == http.ErrServerClosed {
+        log.Println("App stopped")
+        return nil
+    }
+    return helpers.WrapErr(err, "webhook server error")
+}
+
+func (l *WebhookListener) serveUpdate(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+    secretToken := r.Header.Get(secretTokenHeader)
+    if subtle.ConstantTimeCompare([]byte(secretToken), []byte(l.secretToken)) != 1 {
+        w.WriteHeader(http.StatusUnauthorized)
+        return
+    }
+
+    data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUpdateSize))
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "cant read webhook update"))
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    event, err := l.receiver.Receive(data)
+    if err != nil {
+        log.Println(helpers.WrapErr(err, "cant receive webhook update"))
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+
+    timer := time.NewTimer(queueTimeout)
+    defer timer.Stop()
+    select {
+    case l.updates <- event:
+        w.WriteHeader(http.StatusOK)
+    case <-r.Context().Done():
+        w.WriteHeader(http.StatusServiceUnavailable)
+    case <-timer.C:
+        log.Println("webhook updates queue is full, telegram will retry the update")
+        w.WriteHeader(http.StatusServiceUnavailable)
+    }
+}
+
+func (l *WebhookListener) processUpdates() {
+    for event := range l.updates {
+        if err := l.handleEvents([]events.Event{event}); err != nil {
+            log.Println(helpers.WrapErr(err, "Cant handleEvents from webhook"))
+            continue
+        }
+        l.handleLostEvents()
+    }
+}
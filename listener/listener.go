@@ -1,5 +1,7 @@
 package listener
 
+import "context"
+
 type Listener interface {
-    Start() error
+    Start(ctx context.Context) error
 }
\ No newline at end of file
@@ -4,7 +4,11 @@ import (
     "context"
     "flag"
     "log"
+    "net/url"
     "os"
+    "os/signal"
+    "strconv"
+    "syscall"
     "time"
     tgClient "user-handler-bot/clients/telegram"
     event "user-handler-bot/events/telegram"
@@ -16,6 +20,10 @@ import (
 const (
     sqliteStoragePath = "./storage.db"
     batchSize         = 10
+    modePolling       = "polling"
+    modeWebhook       = "webhook"
+    defaultWebhookListenAddr = ":8080"
+    defaultPollTimeout       = 50
 )
 
 func main () {
@@ -24,6 +32,11 @@ func main () {
         "",
         "token for access to telegram bot",
     )
+    mode := flag.String(
+        "mode",
+        modePolling,
+        "how to receive updates: polling or webhook",
+    )
     flag.Parse()
 
     if *token == "" {
@@ -51,15 +64,59 @@ func main () {
 
     event := event.New(&tgClient, storage)
 
-    listener := event_telegram_listener.New(
-        event,
-        event,
-        batchSize,
-    )
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    switch *mode {
+    case modePolling:
+        pollTimeout, err := strconv.Atoi(getEnv("POLL_TIMEOUT", strconv.Itoa(defaultPollTimeout)))
+        if err != nil {
+            log.Fatal("can't parse POLL_TIMEOUT: ", err)
+        }
+        if err := tgClient.DeleteWebhook(); err != nil {
+            log.Fatal("can't delete webhook: ", err)
+        }
+        listener := event_telegram_listener.New(
+            event,
+            event,
+            batchSize,
+            pollTimeout,
+        )
+        if err := listener.Start(ctx); err != nil {
+            log.Fatal("bot is stopped", err)
+        }
+    case modeWebhook:
+        webhookUrl := checkRequiredEnv("WEBHOOK_URL")
+        secretToken := checkRequiredEnv("WEBHOOK_SECRET")
+        listenAddr := getEnv("WEBHOOK_LISTEN_ADDR", defaultWebhookListenAddr)
+        parsedUrl, err := url.Parse(webhookUrl)
+        if err != nil {
+            log.Fatal("can't parse WEBHOOK_URL: ", err)
+        }
+        if err := tgClient.SetWebhook(webhookUrl, secretToken); err != nil {
+            log.Fatal("can't set webhook: ", err)
+        }
+        listener := event_telegram_listener.NewWebhook(
+            event,
+            event,
+            event,
+            listenAddr,
+            getWebhookPath(parsedUrl),
+            secretToken,
+        )
+        if err := listener.Start(ctx); err != nil {
+            log.Fatal("bot is stopped", err)
+        }
+    default:
+        log.Fatal("unknown mode: ", *mode)
+    }
+}
 
-    if err := listener.Start(); err != nil {
-        log.Fatal("bot is stopped", err)
+func getWebhookPath(webhookUrl *url.URL) string {
+    if webhookUrl.Path == "" {
+        return "/"
     }
+    return webhookUrl.Path
 }
 
 func setLogFormat() {
@@ -67,6 +124,14 @@ func setLogFormat() {
     log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 }
 
+func getEnv(name string, fallback string) string {
+    val := os.Getenv(name)
+    if val == "" {
+        return fallback
+    }
+    return val
+}
+
 func checkRequiredEnv(name string) string {
     val := os.Getenv(name)
     if os.Getenv(name) == "" {
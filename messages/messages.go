@@ -20,6 +20,57 @@ var (
     KEYBOARD_THIS_IS_MSG_TO_REQUEST_TO_JOIN = getenv("KEYBOARD_THIS_IS_MSG_TO_REQUEST_TO_JOIN", "^This is current message to request to join")
     KEYBOARD_ACCEPTANCE_DELAY = getenv("KEYBOARD_ACCEPTANCE_DELAY", "Acceptance delay for request to join")
     KEYBOARD_CHECK_NOT_ACCEPTED_USERS = getenv("KEYBOARD_CHECK_NOT_ACCEPTED_USERS", "Show info about not accepted users")
+    KEYBOARD_CAMPAIGNS = getenv("KEYBOARD_CAMPAIGNS", "Campaigns")
+    KEYBOARD_NEW_CAMPAIGN = getenv("KEYBOARD_NEW_CAMPAIGN", "New campaign")
+    KEYBOARD_RENAME_CAMPAIGN = getenv("KEYBOARD_RENAME_CAMPAIGN", "Rename")
+    KEYBOARD_CONFIRM_CAMPAIGN = getenv("KEYBOARD_CONFIRM_CAMPAIGN", "Confirm")
+    KEYBOARD_TEST_SEND_CAMPAIGN = getenv("KEYBOARD_TEST_SEND_CAMPAIGN", "Send test to admins")
+    KEYBOARD_CANCEL_CAMPAIGN = getenv("KEYBOARD_CANCEL_CAMPAIGN", "Cancel")
+    KEYBOARD_DELETE_CAMPAIGN = getenv("KEYBOARD_DELETE_CAMPAIGN", "Delete campaign")
+    KEYBOARD_BACK_TO_CAMPAIGNS = getenv("KEYBOARD_BACK_TO_CAMPAIGNS", "Back to campaigns")
+    KEYBOARD_BACK_TO_CAMPAIGN = getenv("KEYBOARD_BACK_TO_CAMPAIGN", "Back to campaign")
+    KEYBOARD_REPEAT_CAMPAIGN = getenv("KEYBOARD_REPEAT_CAMPAIGN", "Repeat on schedule")
+    KEYBOARD_RECURRING_BROADCASTS = getenv("KEYBOARD_RECURRING_BROADCASTS", "Recurring")
+    KEYBOARD_PAUSE = getenv("KEYBOARD_PAUSE", "Pause")
+    KEYBOARD_RESUME = getenv("KEYBOARD_RESUME", "Resume")
+    KEYBOARD_DELETE_RECURRING_BROADCAST = getenv("KEYBOARD_DELETE_RECURRING_BROADCAST", "Delete schedule")
+    KEYBOARD_CAMPAIGN_TTL = getenv("KEYBOARD_CAMPAIGN_TTL", "Auto-delete")
+    KEYBOARD_REQUEST_MESSAGE_TTL = getenv("KEYBOARD_REQUEST_MESSAGE_TTL", "Auto-delete message to request to join")
+    KEYBOARD_TEMPLATES = getenv("KEYBOARD_TEMPLATES", "Templates")
+    KEYBOARD_NEW_TEMPLATE = getenv("KEYBOARD_NEW_TEMPLATE", "New template")
+    KEYBOARD_PREVIEW_TEMPLATE = getenv("KEYBOARD_PREVIEW_TEMPLATE", "Preview")
+    KEYBOARD_SET_TEMPLATE_MESSAGE = getenv("KEYBOARD_SET_TEMPLATE_MESSAGE", "Replace message")
+    KEYBOARD_RENAME_TEMPLATE = getenv("KEYBOARD_RENAME_TEMPLATE", "Rename")
+    KEYBOARD_DELETE_TEMPLATE = getenv("KEYBOARD_DELETE_TEMPLATE", "Delete template")
+    KEYBOARD_NEW_CAMPAIGN_FROM_TEMPLATE = getenv("KEYBOARD_NEW_CAMPAIGN_FROM_TEMPLATE", "New campaign with this message")
+    KEYBOARD_USE_TEMPLATE_FOR_REQUEST = getenv("KEYBOARD_USE_TEMPLATE_FOR_REQUEST", "Use as message to request to join")
+    KEYBOARD_BACK_TO_TEMPLATES = getenv("KEYBOARD_BACK_TO_TEMPLATES", "Back to templates")
+    KEYBOARD_BACK_TO_TEMPLATE = getenv("KEYBOARD_BACK_TO_TEMPLATE", "Back to template")
+    KEYBOARD_PICK_TEMPLATE = getenv("KEYBOARD_PICK_TEMPLATE", "Template: ")
+    KEYBOARD_AUDIENCE = getenv("KEYBOARD_AUDIENCE", "Audience")
+    KEYBOARD_AUDIENCE_CHANNEL = getenv("KEYBOARD_AUDIENCE_CHANNEL", "Channel")
+    KEYBOARD_AUDIENCE_JOINED = getenv("KEYBOARD_AUDIENCE_JOINED", "Joined between")
+    KEYBOARD_AUDIENCE_RECEIVED = getenv("KEYBOARD_AUDIENCE_RECEIVED", "Received campaign")
+    KEYBOARD_AUDIENCE_NOT_RECEIVED = getenv("KEYBOARD_AUDIENCE_NOT_RECEIVED", "Did not receive campaign")
+    KEYBOARD_AUDIENCE_RESET = getenv("KEYBOARD_AUDIENCE_RESET", "All users")
+    KEYBOARD_RECALL_CAMPAIGN = getenv("KEYBOARD_RECALL_CAMPAIGN", "Delete everywhere")
+    KEYBOARD_CONFIRM_RECALL_CAMPAIGN = getenv("KEYBOARD_CONFIRM_RECALL_CAMPAIGN", "Yes, delete it in all chats")
+    KEYBOARD_EDIT_CAMPAIGN_TEXT = getenv("KEYBOARD_EDIT_CAMPAIGN_TEXT", "Edit text everywhere")
+    KEYBOARD_NOT_DELIVERED_USERS = getenv("KEYBOARD_NOT_DELIVERED_USERS", "Who did not get the message")
+    KEYBOARD_OUTBOX = getenv("KEYBOARD_OUTBOX", "Outgoing messages")
+    KEYBOARD_RETRY_FAILED_OUTBOX = getenv("KEYBOARD_RETRY_FAILED_OUTBOX", "Retry failed messages")
+    KEYBOARD_REVIEW_REQUESTS = getenv("KEYBOARD_REVIEW_REQUESTS", "Review requests to join")
+    KEYBOARD_APPROVE_REQUEST = getenv("KEYBOARD_APPROVE_REQUEST", "Approve")
+    KEYBOARD_DECLINE_REQUEST = getenv("KEYBOARD_DECLINE_REQUEST", "Decline")
+    KEYBOARD_CAPTCHA = getenv("KEYBOARD_CAPTCHA", "Captcha before approval")
+    KEYBOARD_JOIN_RULES = getenv("KEYBOARD_JOIN_RULES", "Rules to decline requests")
+    KEYBOARD_JOIN_DECISIONS = getenv("KEYBOARD_JOIN_DECISIONS", "Declined requests")
+    KEYBOARD_DELETE_JOIN_RULE = getenv("KEYBOARD_DELETE_JOIN_RULE", "Delete: ")
+    KEYBOARD_OFF_REQUEST_CARDS = getenv("KEYBOARD_OFF_REQUEST_CARDS", "Notify admins about requests: Off")
+    KEYBOARD_ON_REQUEST_CARDS = getenv("KEYBOARD_ON_REQUEST_CARDS", "Notify admins about requests: On")
+    KEYBOARD_SKIP_REQUEST = getenv("KEYBOARD_SKIP_REQUEST", "Skip")
+    KEYBOARD_PREVIOUS_PAGE = getenv("KEYBOARD_PREVIOUS_PAGE", "« Previous")
+    KEYBOARD_NEXT_PAGE = getenv("KEYBOARD_NEXT_PAGE", "Next »")
 
 
     ACESS_DENIED = getenv("ACCESS_DENIED", "Access is denied")
@@ -28,19 +79,137 @@ var (
     SEND_MESSAGE_WILL_BE_SENT = getenv("SEND_MESSAGE_WILL_BE_SENT", "Message for all users will be sent: ")
     NOT_ACTIVE_SEND_MESSAGE_FOR_ALL = getenv("NOT_ACTIVE_SEND_MESSAGE_FOR_ALL", "No active mailings found")
     SET_TIME_FOR_SENDING_MESSAGE = getenv("SET_TIME_FOR_SENDING_MESSAGE", "Send message with time for sent message to all users in format: day.month.year hours:minutes like 02.01.2006 15:04")
-    SET_SENDING_MESSAGE = getenv("SET_SENDING_MESSAGE", "Send a message to send to all users in this chat and do not delete it before sending")
-    SET_REQUEST_TO_JOIN_MESSAGE = getenv("SET_REQUEST_TO_JOIN_MESSAGE", "Send a message that will be sent when accepted into the group and do not delete it until you change to a new one")
+    SET_SENDING_MESSAGE = getenv("SET_SENDING_MESSAGE", "Send a message to send to all users in this chat. Text, photo, video, document, audio and albums are saved by the bot, other messages are copied from this chat, do not delete them before sending")
+    SET_REQUEST_TO_JOIN_MESSAGE = getenv("SET_REQUEST_TO_JOIN_MESSAGE", "Send a message that will be sent when accepted into the group. Text, photo, video, document, audio and albums are saved by the bot, other messages are copied from this chat, do not delete them until you change to a new one")
     APPROVE_NOT_ACCEPTED_USERS = getenv("APPROVE_NOT_ACCEPTED_USERS", "Approve not accepted users")
     NOT_ACCEPTED_USERS = getenv("NOT_ACCEPTED_USERS", "The number of unaccepted users in the database: ")
     START_ACCEPT_USERS = getenv("START_ACCEPT_USERS", "Accept users was started")
+    REVIEW_QUEUE_EMPTY = getenv("REVIEW_QUEUE_EMPTY", "There are no requests to join waiting for review")
+    REVIEW_REQUEST = getenv("REVIEW_REQUEST", "Request to join ")
+    REVIEW_REQUEST_NAME = getenv("REVIEW_REQUEST_NAME", "Name: ")
+    REVIEW_REQUEST_USERNAME = getenv("REVIEW_REQUEST_USERNAME", "Username: ")
+    REVIEW_REQUEST_BIO = getenv("REVIEW_REQUEST_BIO", "Bio: ")
+    REVIEW_REQUEST_CHANNEL = getenv("REVIEW_REQUEST_CHANNEL", "Channel: ")
+    REVIEW_REQUEST_DATE = getenv("REVIEW_REQUEST_DATE", "Requested: ")
+    REQUEST_APPROVED = getenv("REQUEST_APPROVED", "The request was approved")
+    REQUEST_DECLINED = getenv("REQUEST_DECLINED", "The request was declined")
+    REQUEST_ALREADY_HANDLED = getenv("REQUEST_ALREADY_HANDLED", "The request was already handled")
+    REQUEST_DECIDED_BY = getenv("REQUEST_DECIDED_BY", "Decided by: ")
+    NEW_REQUEST_TO_JOIN = getenv("NEW_REQUEST_TO_JOIN", "New request to join")
+    SCREENING_INTRO = getenv("SCREENING_INTRO", "Thank you for the request to join. Please answer a few questions before it is approved")
+    SCREENING_ANSWER_REQUIRED = getenv("SCREENING_ANSWER_REQUIRED", "Please answer the question, choose one of the buttons if there are any")
+    SCREENING_FINISHED = getenv("SCREENING_FINISHED", "Thank you for the answers, the request will be decided soon")
+    SCREENING_NEEDS_REVIEW = getenv("SCREENING_NEEDS_REVIEW", "Answers to the questionnaire need a review")
+    SCREENING_ANSWERS = getenv("SCREENING_ANSWERS", "Answers:")
+    SCREENING_DECIDER = getenv("SCREENING_DECIDER", "questionnaire")
+    CAPTCHA_DEADLINE = getenv("CAPTCHA_DEADLINE", "Time for users to solve the captcha before the request is approved (never means no captcha): ")
+    CAPTCHA_STATS = getenv("CAPTCHA_STATS", "Captchas:")
+    CAPTCHA_CHALLENGE = getenv("CAPTCHA_CHALLENGE", "Please solve the captcha to get the request to join approved. Time to answer: ")
+    CAPTCHA_ARITHMETIC = getenv("CAPTCHA_ARITHMETIC", "Choose the answer: ")
+    CAPTCHA_EMOJI = getenv("CAPTCHA_EMOJI", "Choose the picture: ")
+    CAPTCHA_PASSED = getenv("CAPTCHA_PASSED", "Right, the request to join is approved")
+    CAPTCHA_FAILED = getenv("CAPTCHA_FAILED", "Wrong answer, the request to join is declined")
+    CAPTCHA_EXPIRED = getenv("CAPTCHA_EXPIRED", "Time is over, the request to join is declined")
+    JOIN_RULES = getenv("JOIN_RULES", "Requests to join which match any rule are declined automatically:")
+    JOIN_RULES_EMPTY = getenv("JOIN_RULES_EMPTY", "There are no rules")
+    JOIN_RULE_FROM_FILE = getenv("JOIN_RULE_FROM_FILE", "(rules.json)")
+    JOIN_RULE_ADDED = getenv("JOIN_RULE_ADDED", "The rule is added")
+    JOIN_RULE_DELETED = getenv("JOIN_RULE_DELETED", "The rule is deleted")
+    JOIN_RULE_WRONG_VALUE = getenv("JOIN_RULE_WRONG_VALUE", "The value does not fit the rule: ")
+    JOIN_RULE_USER_ID = getenv("JOIN_RULE_USER_ID", "User id")
+    JOIN_RULE_USERNAME = getenv("JOIN_RULE_USERNAME", "Username")
+    JOIN_RULE_FIRST_NAME = getenv("JOIN_RULE_FIRST_NAME", "First name")
+    JOIN_RULE_NO_USERNAME = getenv("JOIN_RULE_NO_USERNAME", "No username")
+    JOIN_RULE_LANGUAGE = getenv("JOIN_RULE_LANGUAGE", "Language")
+    JOIN_RULE_REPEATED = getenv("JOIN_RULE_REPEATED", "Repeated request within")
+    JOIN_RULE_BANNED = getenv("JOIN_RULE_BANNED", "Banned in the channel")
+    SET_JOIN_RULE_USER_ID = getenv("SET_JOIN_RULE_USER_ID", "Send the id of the user to block")
+    SET_JOIN_RULE_PATTERN = getenv("SET_JOIN_RULE_PATTERN", "Send a regular expression, for example (?i)casino|crypto")
+    SET_JOIN_RULE_LANGUAGE = getenv("SET_JOIN_RULE_LANGUAGE", "Send the language code of the user, for example fa")
+    SET_JOIN_RULE_WINDOW = getenv("SET_JOIN_RULE_WINDOW", "Send the time within which a repeated request is declined, for example 24h")
+    JOIN_DECISIONS = getenv("JOIN_DECISIONS", "Declined requests to join:")
+    JOIN_DECISIONS_EMPTY = getenv("JOIN_DECISIONS_EMPTY", "No requests were declined")
+    CAMPAIGNS_LIST = getenv("CAMPAIGNS_LIST", "Campaigns, choose one or create a new one")
+    CAMPAIGN = getenv("CAMPAIGN", "Campaign: ")
+    CAMPAIGN_STATUS = getenv("CAMPAIGN_STATUS", "Status: ")
+    SET_CAMPAIGN_NAME = getenv("SET_CAMPAIGN_NAME", "Send a name for the campaign")
+    CAMPAIGN_PREVIEW = getenv("CAMPAIGN_PREVIEW", "Preview of the campaign. It starts only after Confirm is pressed")
+    CAMPAIGN_TEST = getenv("CAMPAIGN_TEST", "Test of the campaign, only admins got this message")
+    CAMPAIGN_NOT_FOUND = getenv("CAMPAIGN_NOT_FOUND", "Campaign not found")
+    CAMPAIGN_CANT_BE_CHANGED = getenv("CAMPAIGN_CANT_BE_CHANGED", "The campaign can not be changed in this status")
+    CAMPAIGN_DELETED = getenv("CAMPAIGN_DELETED", "Campaign was deleted")
+    RECURRING_BROADCASTS_LIST = getenv("RECURRING_BROADCASTS_LIST", "Recurring broadcasts")
+    RECURRING_BROADCAST_SCHEDULE = getenv("RECURRING_BROADCAST_SCHEDULE", "Schedule: ")
+    RECURRING_BROADCAST_NEXT_RUN = getenv("RECURRING_BROADCAST_NEXT_RUN", "Next run: ")
+    RECURRING_BROADCAST_LAST_RUN = getenv("RECURRING_BROADCAST_LAST_RUN", "Last run: ")
+    RECURRING_BROADCAST_PAUSED = getenv("RECURRING_BROADCAST_PAUSED", "paused")
+    RECURRING_BROADCAST_NOT_FOUND = getenv("RECURRING_BROADCAST_NOT_FOUND", "Recurring broadcast not found")
+    RECURRING_BROADCAST_DELETED = getenv("RECURRING_BROADCAST_DELETED", "Recurring broadcast was deleted")
+    SET_SCHEDULE = getenv("SET_SCHEDULE", "Send a schedule in cron format: minute hour day month weekday. For example: 0 10 * * * every day at 10:00, 0 10 * * 1 every monday, 0 10 1 * * first day of month")
+    SET_CAMPAIGN_TTL = getenv("SET_CAMPAIGN_TTL", "Send how long the message stays in users chats before the bot deletes it, for example 90m or 24h, at most 48h. Send 0 to keep it")
+    CAMPAIGN_TTL = getenv("CAMPAIGN_TTL", "Delete from chats after: ")
+    TTL_OFF = getenv("TTL_OFF", "never")
+    REQUEST_MESSAGE_TTL = getenv("REQUEST_MESSAGE_TTL", "Delete the message to request to join from user chat after:")
+    TEMPLATES_LIST = getenv("TEMPLATES_LIST", "Templates")
+    TEMPLATE = getenv("TEMPLATE", "Template: ")
+    TEMPLATE_CREATED = getenv("TEMPLATE_CREATED", "Created: ")
+    TEMPLATE_NOT_FOUND = getenv("TEMPLATE_NOT_FOUND", "Template not found")
+    TEMPLATE_DELETED = getenv("TEMPLATE_DELETED", "Template was deleted")
+    TEMPLATE_HAS_NO_MESSAGE = getenv("TEMPLATE_HAS_NO_MESSAGE", "The template has no message yet")
+    TEMPLATE_COPIED_FROM_CHAT = getenv("TEMPLATE_COPIED_FROM_CHAT", "This message is copied from your chat, do not delete it there")
+    PLACEHOLDERS_HINT = getenv("PLACEHOLDERS_HINT", "Text and captions may greet every user with {first_name}, {last_name}, {username}, {channel_title} and {join_date}")
+    SET_TEMPLATE_NAME = getenv("SET_TEMPLATE_NAME", "Send a name of the template")
+    SET_TEMPLATE_MESSAGE = getenv("SET_TEMPLATE_MESSAGE", "Send a message to save in the template")
+    AUDIENCE = getenv("AUDIENCE", "Audience: ")
+    AUDIENCE_ALL_USERS = getenv("AUDIENCE_ALL_USERS", "all users")
+    AUDIENCE_CHANNEL = getenv("AUDIENCE_CHANNEL", "member of channel: ")
+    AUDIENCE_JOINED_FROM = getenv("AUDIENCE_JOINED_FROM", "joined from: ")
+    AUDIENCE_JOINED_TO = getenv("AUDIENCE_JOINED_TO", "joined to: ")
+    AUDIENCE_LEFT_CHANNELS = getenv("AUDIENCE_LEFT_CHANNELS", "left channels: ")
+    AUDIENCE_RECEIVED = getenv("AUDIENCE_RECEIVED", "received campaign: ")
+    AUDIENCE_NOT_RECEIVED = getenv("AUDIENCE_NOT_RECEIVED", "did not receive campaign: ")
+    AUDIENCE_HAS_USERNAME = getenv("AUDIENCE_HAS_USERNAME", "has username: ")
+    AUDIENCE_ANY = getenv("AUDIENCE_ANY", "any")
+    AUDIENCE_YES = getenv("AUDIENCE_YES", "yes")
+    AUDIENCE_NO = getenv("AUDIENCE_NO", "no")
+    SET_AUDIENCE_CHANNEL = getenv("SET_AUDIENCE_CHANNEL", "Send the channel id, for example -1001234567890")
+    SET_AUDIENCE_JOINED = getenv("SET_AUDIENCE_JOINED", "Send dates in format: day.month.year - day.month.year like 01.01.2024 - 31.01.2024, or one date to take users joined after it")
+    SET_AUDIENCE_BROADCAST = getenv("SET_AUDIENCE_BROADCAST", "Send the campaign number:")
+    CAMPAIGN_IN_PROGRESS = getenv("CAMPAIGN_IN_PROGRESS", "Sending campaign: ")
+    CAMPAIGN_FINISHED = getenv("CAMPAIGN_FINISHED", "Campaign finished: ")
+    PROGRESS_SENT = getenv("PROGRESS_SENT", "Sent: ")
+    PROGRESS_OF = getenv("PROGRESS_OF", " of ")
+    PROGRESS_FAILED = getenv("PROGRESS_FAILED", "Failed: ")
+    PROGRESS_REMAINING = getenv("PROGRESS_REMAINING", "Remaining: ")
+    PROGRESS_RATE = getenv("PROGRESS_RATE", "Sent in the last minute: ")
+    PROGRESS_ETA = getenv("PROGRESS_ETA", "Time left: ")
+    CONFIRM_RECALL_CAMPAIGN = getenv("CONFIRM_RECALL_CAMPAIGN", "The message of the campaign will be deleted in chats of all users who got it. Telegram allows to delete messages only for 48 hours after sending")
+    SET_CAMPAIGN_NEW_TEXT = getenv("SET_CAMPAIGN_NEW_TEXT", "Send the new text, it will replace the text or the caption of the message in chats of all users who got it")
+    CAMPAIGN_CHANGE_QUEUED = getenv("CAMPAIGN_CHANGE_QUEUED", "Messages queued for the change: ")
+    CAMPAIGN_CHANGE_IN_PROGRESS = getenv("CAMPAIGN_CHANGE_IN_PROGRESS", "The previous change of this campaign is still in progress")
+    CAMPAIGN_DELETED_EVERYWHERE = getenv("CAMPAIGN_DELETED_EVERYWHERE", "Deleted in chats: ")
+    CAMPAIGN_EDITED_EVERYWHERE = getenv("CAMPAIGN_EDITED_EVERYWHERE", "Edited in chats: ")
+    CAMPAIGN_EXPIRED = getenv("CAMPAIGN_EXPIRED", "Deleted after TTL: ")
+    CAMPAIGN_CHANGE_FAILED = getenv("CAMPAIGN_CHANGE_FAILED", ", failed: ")
+    CAMPAIGN_CHANGE_REMAINING = getenv("CAMPAIGN_CHANGE_REMAINING", ", remaining: ")
+    QUEUED = getenv("QUEUED", "In the queue: ")
+    PAUSED_IN_QUEUE = getenv("PAUSED_IN_QUEUE", "Paused in the queue: ")
+    CANCELLED_NOT_SENT = getenv("CANCELLED_NOT_SENT", "Cancelled and not sent: ")
+    DELIVERIES_BY_STATUS = getenv("DELIVERIES_BY_STATUS", "Deliveries by status:")
+    NOT_DELIVERED_USERS = getenv("NOT_DELIVERED_USERS", "Users who did not get the message:")
+    OUTBOX_STATUS = getenv("OUTBOX_STATUS", "Outgoing messages by status:")
+    OUTBOX_LAST_ERRORS = getenv("OUTBOX_LAST_ERRORS", "Last failed messages:")
+    OUTBOX_RETRIED = getenv("OUTBOX_RETRIED", "Failed messages returned to the queue: ")
 
     ERR_PARSE_TIME_FOR_SENT_MSG_TO_ALL = getenv("ERR_PARSE_TIME_FOR_SENT_MSG_TO_ALL", "Can not parse this time check format, required: 02.01.2006 15:04 dd.mm.yyyy hh:mm")
+    ERR_PARSE_SCHEDULE = getenv("ERR_PARSE_SCHEDULE", "Can not parse this schedule, required: minute hour day month weekday like 0 10 * * 1")
+    ERR_PARSE_TTL = getenv("ERR_PARSE_TTL", "Can not parse this time, required a duration from 0 to 48h like 90m or 24h")
+    ERR_PARSE_AUDIENCE = getenv("ERR_PARSE_AUDIENCE", "Can not parse this value for the audience filter")
     ERR_MSG_TO_ALL_NOT_FOUND = getenv("ERR_MSG_TO_ALL_NOT_FOUND", "Message to sent all users not found")
 
     USERS_NOT_FOUND = getenv("USERS_NOT_FOUND", "users not found ")
     SENT = getenv("SENT", "sent")
-    USERS_IN_DB = getenv("USERS_IN_DB", "users in db:")
-    MESSAGE_WAS_SENT = getenv("MESSAGE_WAS_SENT", "The message was sent")
+    RECIPIENTS = getenv("RECIPIENTS", "recipients:")
     TIME_FOR_SENDING_NOT_FOUND = getenv("TIME_FOR_SENDING_NOT_FOUND", "Time for sending message is not found")
 )
 
@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+    "context"
+    "encoding/json"
+    "strings"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+// GetCountAudience returns how many users match the audience right now
+func (s *Storage) GetCountAudience(ctx context.Context, audience storage.Audience) (int, error) {
+    where, args := audienceFilter(audience)
+    query := `SELECT COUNT(*) FROM users WHERE ` + where
+    var count int
+    if err := s.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+        return 0, helpers.WrapErr(err, "cant GetCountAudience")
+    }
+    return count, nil
+}
+
+// audienceFilter makes the WHERE condition for users table, channels are json arrays of ids
+func audienceFilter(audience storage.Audience) (string, []interface{}) {
+    conditions := []string{"1 = 1"}
+    var args []interface{}
+    if audience.ChannelId != "" {
+        conditions = append(conditions, `json_valid(users.channels) AND EXISTS (SELECT 1 FROM json_each(users.channels) WHERE value = ?)`)
+        args = append(args, audience.ChannelId)
+    }
+    if audience.JoinedFrom != nil {
+        conditions = append(conditions, `users.date_create >= ?`)
+        args = append(args, *audience.JoinedFrom)
+    }
+    if audience.JoinedTo != nil {
+        conditions = append(conditions, `users.date_create < ?`)
+        args = append(args, *audience.JoinedTo)
+    }
+    if audience.LeftChannels != nil {
+        left := `(json_valid(users.leaved_channels) AND json_array_length(users.leaved_channels) > 0)`
+        if !*audience.LeftChannels {
+            left = "NOT " + left
+        }
+        conditions = append(conditions, left)
+    }
+    if audience.ReceivedBroadcast > 0 {
+        conditions = append(conditions, `EXISTS (SELECT 1 FROM deliveries 
+            WHERE deliveries.broadcast_id = ? AND deliveries.user_id = users.id AND deliveries.status = ?)`)
+        args = append(args, audience.ReceivedBroadcast, storage.DeliveryStatusSent)
+    }
+    if audience.NotReceivedBroadcast > 0 {
+        conditions = append(conditions, `NOT EXISTS (SELECT 1 FROM deliveries 
+            WHERE deliveries.broadcast_id = ? AND deliveries.user_id = users.id AND deliveries.status = ?)`)
+        args = append(args, audience.NotReceivedBroadcast, storage.DeliveryStatusSent)
+    }
+    if audience.HasUsername != nil {
+        if *audience.HasUsername {
+            conditions = append(conditions, `users.username != ''`)
+        } else {
+            conditions = append(conditions, `users.username = ''`)
+        }
+    }
+    return strings.Join(conditions, " AND "), args
+}
+
+func marshalAudience(audience storage.Audience) (string, error) {
+    if audience == (storage.Audience{}) {
+        return "", nil
+    }
+    audienceJson, err := json.Marshal(audience)
+    if err != nil {
+        return "", helpers.WrapErr(err, "cant marshal audience")
+    }
+    return string(audienceJson), nil
+}
+
+func unmarshalAudience(audienceJson string) (storage.Audience, error) {
+    var audience storage.Audience
+    if audienceJson == "" {
+        return audience, nil
+    }
+    err := json.Unmarshal([]byte(audienceJson), &audience)
+    return audience, helpers.WrapErr(err, "cant unmarshal audience")
+}
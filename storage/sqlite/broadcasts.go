@@ -0,0 +1,96 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "strconv"
+    "time"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+// EnqueueBroadcastBatch adds messages for a batch of users and moves the broadcast checkpoint in one transaction,
+// so after a restart the broadcast continues from the first not enqueued user.
+// It returns false and enqueues nothing when the campaign is not being sent anymore
+func (s *Storage) EnqueueBroadcastBatch(ctx context.Context, broadcastId int, items []storage.OutboxItem, lastUserId int) (bool, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return false, helpers.WrapErr(err, "cant EnqueueBroadcastBatch begin tx")
+    }
+    defer tx.Rollback()
+
+    // a paused or cancelled campaign must not get new messages in the outbox
+    var status string
+    statusQuery := `SELECT status FROM campaigns WHERE id = ?`
+    err = tx.QueryRowContext(ctx, statusQuery, broadcastId).Scan(&status)
+    if err != nil && err != sql.ErrNoRows {
+        return false, helpers.WrapErr(err, "cant EnqueueBroadcastBatch check campaign status")
+    }
+    if status != storage.CampaignStatusSending {
+        return false, nil
+    }
+
+    // the unique index on broadcast recipients skips users who already have this broadcast in the outbox
+    query := `INSERT OR IGNORE INTO outbox (chat_id, kind, payload, priority, status, next_attempt_at, date_create, source, source_id) 
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+    now := time.Now()
+    for _, item := range items {
+        _, err := tx.ExecContext(
+            ctx,
+            query,
+            item.ChatId,
+            item.Kind,
+            item.Payload,
+            item.Priority,
+            storage.OutboxStatusPending,
+            now,
+            now,
+            item.Source,
+            item.SourceId,
+        )
+        if err != nil {
+            return false, helpers.WrapErr(err, "cant EnqueueBroadcastBatch for chat " + strconv.Itoa(item.ChatId))
+        }
+    }
+
+    checkpoint := `INSERT OR REPLACE INTO broadcast_checkpoints (broadcast_id, last_user_id, finished) VALUES (?, ?, false)`
+    if _, err := tx.ExecContext(ctx, checkpoint, broadcastId, lastUserId); err != nil {
+        return false, helpers.WrapErr(err, "cant EnqueueBroadcastBatch save checkpoint")
+    }
+
+    if err := tx.Commit(); err != nil {
+        return false, helpers.WrapErr(err, "cant EnqueueBroadcastBatch commit")
+    }
+    return true, nil
+}
+
+// GetBroadcastCheckpoint returns id of the last enqueued user and whether all users were enqueued
+func (s *Storage) GetBroadcastCheckpoint(ctx context.Context, broadcastId int) (int, bool, error) {
+    var lastUserId int
+    var finished bool
+    query := `SELECT last_user_id, finished FROM broadcast_checkpoints WHERE broadcast_id = ?`
+    err := s.db.QueryRowContext(ctx, query, broadcastId).Scan(&lastUserId, &finished)
+    if err == sql.ErrNoRows {
+        return 0, false, nil
+    }
+    if err != nil {
+        return 0, false, helpers.WrapErr(err, "cant GetBroadcastCheckpoint broadcast " + strconv.Itoa(broadcastId))
+    }
+    return lastUserId, finished, nil
+}
+
+func (s *Storage) FinishBroadcastCheckpoint(ctx context.Context, broadcastId int) error {
+    query := `UPDATE broadcast_checkpoints SET finished = true WHERE broadcast_id = ?`
+    if _, err := s.db.ExecContext(ctx, query, broadcastId); err != nil {
+        return helpers.WrapErr(err, "cant FinishBroadcastCheckpoint broadcast " + strconv.Itoa(broadcastId))
+    }
+    return nil
+}
+
+func (s *Storage) DeleteBroadcastCheckpoint(ctx context.Context, broadcastId int) error {
+    query := `DELETE FROM broadcast_checkpoints WHERE broadcast_id = ?`
+    if _, err := s.db.ExecContext(ctx, query, broadcastId); err != nil {
+        return helpers.WrapErr(err, "cant DeleteBroadcastCheckpoint broadcast " + strconv.Itoa(broadcastId))
+    }
+    return nil
+}
@@ -0,0 +1,282 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "strconv"
+    "strings"
+    "time"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+const campaignColumns = `id, name, from_chat_id, message_id, time_for_sent, audience, status, created_by, date_create, 
+    progress_chat_id, progress_message_id, ttl_seconds, content`
+
+func (s *Storage) CreateCampaign(ctx context.Context, campaign storage.Campaign) (int, error) {
+    query := `INSERT INTO campaigns (name, from_chat_id, message_id, time_for_sent, audience, status, created_by, date_create, 
+        progress_chat_id, ttl_seconds, content) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+    audience, err := marshalAudience(campaign.Audience)
+    if err != nil {
+        return 0, err
+    }
+    res, err := s.db.ExecContext(
+        ctx,
+        query,
+        campaign.Name,
+        campaign.FromChatId,
+        campaign.MessageId,
+        campaignTimeToSent(campaign),
+        audience,
+        campaign.Status,
+        campaign.CreatedBy,
+        time.Now(),
+        campaign.ProgressChatId,
+        int(campaign.TTL.Seconds()),
+        campaign.Content,
+    )
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant CreateCampaign " + campaign.Name)
+    }
+    id, err := res.LastInsertId()
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant CreateCampaign LastInsertId")
+    }
+    return int(id), nil
+}
+
+func (s *Storage) GetCampaign(ctx context.Context, id int) (storage.Campaign, error) {
+    query := `SELECT ` + campaignColumns + ` FROM campaigns WHERE id = ?`
+    rows, err := s.db.QueryContext(ctx, query, id)
+    if err != nil {
+        return storage.Campaign{}, helpers.WrapErr(err, "cant GetCampaign " + strconv.Itoa(id))
+    }
+    campaigns, err := scanCampaigns(rows)
+    if err != nil {
+        return storage.Campaign{}, helpers.WrapErr(err, "cant GetCampaign rows")
+    }
+    if len(campaigns) == 0 {
+        return storage.Campaign{}, helpers.WrapErr(sql.ErrNoRows, "cant GetCampaign " + strconv.Itoa(id))
+    }
+    return campaigns[0], nil
+}
+
+// GetCampaigns returns the newest campaigns with one of statuses, all of them if statuses are empty
+func (s *Storage) GetCampaigns(ctx context.Context, statuses []string, limit int) ([]storage.Campaign, error) {
+    query := `SELECT ` + campaignColumns + ` FROM campaigns`
+    var args []interface{}
+    if len(statuses) > 0 {
+        for _, status := range statuses {
+            args = append(args, status)
+        }
+        query += " WHERE status IN (" + placeholders(len(statuses)) + ")"
+    }
+    query += " ORDER BY id DESC LIMIT ?"
+    args = append(args, limit)
+
+    rows, err := s.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetCampaigns")
+    }
+    campaigns, err := scanCampaigns(rows)
+    if err != nil {
+        return campaigns, helpers.WrapErr(err, "cant GetCampaigns rows")
+    }
+    return campaigns, nil
+}
+
+// GetDueCampaigns returns scheduled campaigns whose time has come and campaigns which are still being sent
+func (s *Storage) GetDueCampaigns(ctx context.Context) ([]storage.Campaign, error) {
+    query := `SELECT ` + campaignColumns + ` FROM campaigns 
+        WHERE (status = ? and time_for_sent <= ?) or status = ? ORDER BY time_for_sent, id`
+    rows, err := s.db.QueryContext(ctx, query, storage.CampaignStatusScheduled, time.Now(), storage.CampaignStatusSending)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetDueCampaigns")
+    }
+    campaigns, err := scanCampaigns(rows)
+    if err != nil {
+        return campaigns, helpers.WrapErr(err, "cant GetDueCampaigns rows")
+    }
+    return campaigns, nil
+}
+
+func (s *Storage) UpdateCampaign(ctx context.Context, campaign storage.Campaign) error {
+    query := `UPDATE campaigns SET name = ?, from_chat_id = ?, message_id = ?, time_for_sent = ?, audience = ?, status = ?, 
+        ttl_seconds = ?, content = ? WHERE id = ?`
+    audience, err := marshalAudience(campaign.Audience)
+    if err != nil {
+        return err
+    }
+    _, err = s.db.ExecContext(
+        ctx,
+        query,
+        campaign.Name,
+        campaign.FromChatId,
+        campaign.MessageId,
+        campaignTimeToSent(campaign),
+        audience,
+        campaign.Status,
+        int(campaign.TTL.Seconds()),
+        campaign.Content,
+        campaign.Id,
+    )
+    if err != nil {
+        return helpers.WrapErr(err, "cant UpdateCampaign " + strconv.Itoa(campaign.Id))
+    }
+    return nil
+}
+
+// SwitchCampaignStatus changes the status only if the campaign is in one of from statuses
+func (s *Storage) SwitchCampaignStatus(ctx context.Context, id int, from []string, to string) (bool, error) {
+    return s.switchCampaignStatus(ctx, id, from, to, nil, "")
+}
+
+// PauseCampaign stops a running campaign, its queued messages wait in the outbox until it is resumed
+func (s *Storage) PauseCampaign(ctx context.Context, id int) (bool, error) {
+    return s.switchCampaignStatus(
+        ctx,
+        id,
+        []string{storage.CampaignStatusSending},
+        storage.CampaignStatusPaused,
+        []string{storage.OutboxStatusPending},
+        storage.OutboxStatusPaused,
+    )
+}
+
+func (s *Storage) ResumeCampaign(ctx context.Context, id int) (bool, error) {
+    return s.switchCampaignStatus(
+        ctx,
+        id,
+        []string{storage.CampaignStatusPaused},
+        storage.CampaignStatusSending,
+        []string{storage.OutboxStatusPaused},
+        storage.OutboxStatusPending,
+    )
+}
+
+// CancelCampaign stops a not finished campaign for good, its not sent messages stay in the outbox as cancelled
+func (s *Storage) CancelCampaign(ctx context.Context, id int) (bool, error) {
+    return s.switchCampaignStatus(
+        ctx,
+        id,
+        []string{
+            storage.CampaignStatusDraft,
+            storage.CampaignStatusAwaitingConfirmation,
+            storage.CampaignStatusScheduled,
+            storage.CampaignStatusSending,
+            storage.CampaignStatusPaused,
+        },
+        storage.CampaignStatusCancelled,
+        []string{storage.OutboxStatusPending, storage.OutboxStatusPaused},
+        storage.OutboxStatusCancelled,
+    )
+}
+
+// switchCampaignStatus moves the campaign and its messages in the outbox to new statuses in one transaction
+func (s *Storage) switchCampaignStatus(ctx context.Context, id int, from []string, to string, outboxFrom []string, outboxTo string) (bool, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return false, helpers.WrapErr(err, "cant switchCampaignStatus begin tx")
+    }
+    defer tx.Rollback()
+
+    query := `UPDATE campaigns SET status = ? WHERE id = ? and status IN (` + placeholders(len(from)) + `)`
+    args := []interface{}{to, id}
+    for _, status := range from {
+        args = append(args, status)
+    }
+    res, err := tx.ExecContext(ctx, query, args...)
+    if err != nil {
+        return false, helpers.WrapErr(err, "cant switchCampaignStatus " + strconv.Itoa(id) + " to " + to)
+    }
+    updated, err := res.RowsAffected()
+    if err != nil {
+        return false, helpers.WrapErr(err, "cant switchCampaignStatus RowsAffected")
+    }
+    if updated == 0 {
+        return false, nil
+    }
+
+    if len(outboxFrom) > 0 {
+        outbox := `UPDATE outbox SET status = ? WHERE source = ? and source_id = ? and status IN (` + placeholders(len(outboxFrom)) + `)`
+        args := []interface{}{outboxTo, storage.OutboxSourceBroadcast, id}
+        for _, status := range outboxFrom {
+            args = append(args, status)
+        }
+        if _, err := tx.ExecContext(ctx, outbox, args...); err != nil {
+            return false, helpers.WrapErr(err, "cant switchCampaignStatus outbox of " + strconv.Itoa(id) + " to " + outboxTo)
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return false, helpers.WrapErr(err, "cant switchCampaignStatus commit")
+    }
+    return true, nil
+}
+
+func (s *Storage) SetCampaignProgressMessage(ctx context.Context, id int, chatId int, messageId int) error {
+    query := `UPDATE campaigns SET progress_chat_id = ?, progress_message_id = ? WHERE id = ?`
+    if _, err := s.db.ExecContext(ctx, query, chatId, messageId, id); err != nil {
+        return helpers.WrapErr(err, "cant SetCampaignProgressMessage " + strconv.Itoa(id))
+    }
+    return nil
+}
+
+func placeholders(count int) string {
+    values := make([]string, count)
+    for i := range values {
+        values[i] = "?"
+    }
+    return strings.Join(values, ",")
+}
+
+func (s *Storage) DeleteCampaign(ctx context.Context, id int) error {
+    query := `DELETE FROM campaigns WHERE id = ?;
+        DELETE FROM recurring_broadcasts WHERE campaign_id = ?;`
+    if _, err := s.db.ExecContext(ctx, query, id, id); err != nil {
+        return helpers.WrapErr(err, "cant DeleteCampaign " + strconv.Itoa(id))
+    }
+    return nil
+}
+
+// campaignTimeToSent keeps 0 for campaigns without time like the messages table does
+func campaignTimeToSent(campaign storage.Campaign) interface{} {
+    if campaign.TimeToSent.IsZero() || campaign.TimeToSent.Unix() == 0 {
+        return 0
+    }
+    return campaign.TimeToSent
+}
+
+func scanCampaigns(rows *sql.Rows) ([]storage.Campaign, error) {
+    defer rows.Close()
+    var campaigns []storage.Campaign
+    for rows.Next() {
+        var campaign storage.Campaign
+        var audience string
+        var ttlSeconds int
+        err := rows.Scan(
+            &campaign.Id,
+            &campaign.Name,
+            &campaign.FromChatId,
+            &campaign.MessageId,
+            &campaign.TimeToSent,
+            &audience,
+            &campaign.Status,
+            &campaign.CreatedBy,
+            &campaign.CreatedAt,
+            &campaign.ProgressChatId,
+            &campaign.ProgressMessageId,
+            &ttlSeconds,
+            &campaign.Content,
+        )
+        if err != nil {
+            return campaigns, err
+        }
+        campaign.TTL = time.Duration(ttlSeconds) * time.Second
+        if campaign.Audience, err = unmarshalAudience(audience); err != nil {
+            return campaigns, err
+        }
+        campaigns = append(campaigns, campaign)
+    }
+    return campaigns, rows.Err()
+}
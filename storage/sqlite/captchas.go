@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "strconv"
+    "time"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+const captchaColumns = `id, user_id, chat_id, user_chat_id, event, answer, message_id, status, deadline, date_create`
+
+func (s *Storage) CreateCaptcha(ctx context.Context, captcha storage.Captcha) (int, error) {
+    query := `INSERT INTO captchas (user_id, chat_id, user_chat_id, event, answer, status, deadline, date_create) 
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+    res, err := s.db.ExecContext(
+        ctx,
+        query,
+        captcha.UserId,
+        captcha.ChatId,
+        captcha.UserChatId,
+        captcha.Event,
+        captcha.Answer,
+        storage.CaptchaStatusWaiting,
+        captcha.Deadline,
+        time.Now(),
+    )
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant CreateCaptcha userId: " + strconv.Itoa(captcha.UserId))
+    }
+    id, err := res.LastInsertId()
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant CreateCaptcha LastInsertId")
+    }
+    return int(id), nil
+}
+
+func (s *Storage) GetCaptcha(ctx context.Context, id int) (storage.Captcha, error) {
+    rows, err := s.db.QueryContext(ctx, `SELECT ` + captchaColumns + ` FROM captchas WHERE id = ?`, id)
+    if err != nil {
+        return storage.Captcha{}, helpers.WrapErr(err, "cant GetCaptcha " + strconv.Itoa(id))
+    }
+    captchas, err := scanCaptchas(rows)
+    if err != nil {
+        return storage.Captcha{}, helpers.WrapErr(err, "cant GetCaptcha rows")
+    }
+    if len(captchas) == 0 {
+        return storage.Captcha{}, helpers.WrapErr(sql.ErrNoRows, "cant GetCaptcha " + strconv.Itoa(id))
+    }
+    return captchas[0], nil
+}
+
+func (s *Storage) SetCaptchaMessage(ctx context.Context, id int, messageId int) error {
+    _, err := s.db.ExecContext(ctx, `UPDATE captchas SET message_id = ? WHERE id = ?`, messageId, id)
+    return helpers.WrapErr(err, "cant SetCaptchaMessage " + strconv.Itoa(id))
+}
+
+// FinishCaptcha moves a waiting captcha to the final status, false means it was already finished,
+// so an answer and the deadline can not both decide the request. The decision stays pending
+// until SetCaptchaDecided is called after telegram has got it
+func (s *Storage) FinishCaptcha(ctx context.Context, id int, status string) (bool, error) {
+    query := `UPDATE captchas SET status = ?, decision_pending = true, date_finish = ? WHERE id = ? AND status = ?`
+    res, err := s.db.ExecContext(ctx, query, status, time.Now(), id, storage.CaptchaStatusWaiting)
+    if err != nil {
+        return false, helpers.WrapErr(err, "cant FinishCaptcha " + strconv.Itoa(id))
+    }
+    affected, err := res.RowsAffected()
+    if err != nil {
+        return false, helpers.WrapErr(err, "cant FinishCaptcha RowsAffected")
+    }
+    return affected > 0, nil
+}
+
+func (s *Storage) SetCaptchaDecided(ctx context.Context, id int) error {
+    _, err := s.db.ExecContext(ctx, `UPDATE captchas SET decision_pending = false WHERE id = ?`, id)
+    return helpers.WrapErr(err, "cant SetCaptchaDecided " + strconv.Itoa(id))
+}
+
+// GetExpiredCaptchas returns waiting captchas whose deadline has passed
+func (s *Storage) GetExpiredCaptchas(ctx context.Context) ([]storage.Captcha, error) {
+    query := `SELECT ` + captchaColumns + ` FROM captchas WHERE status = ? AND deadline <= ? ORDER BY id`
+    rows, err := s.db.QueryContext(ctx, query, storage.CaptchaStatusWaiting, time.Now())
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetExpiredCaptchas")
+    }
+    captchas, err := scanCaptchas(rows)
+    return captchas, helpers.WrapErr(err, "cant GetExpiredCaptchas rows")
+}
+
+// GetUndecidedCaptchas returns finished captchas whose decision did not reach telegram before finishedBefore
+func (s *Storage) GetUndecidedCaptchas(ctx context.Context, finishedBefore time.Time) ([]storage.Captcha, error) {
+    query := `SELECT ` + captchaColumns + ` FROM captchas WHERE decision_pending = true AND date_finish <= ? ORDER BY id`
+    rows, err := s.db.QueryContext(ctx, query, finishedBefore)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetUndecidedCaptchas")
+    }
+    captchas, err := scanCaptchas(rows)
+    return captchas, helpers.WrapErr(err, "cant GetUndecidedCaptchas rows")
+}
+
+func (s *Storage) GetCaptchaStats(ctx context.Context) (map[string]int, error) {
+    rows, err := s.db.QueryContext(ctx, `SELECT status, COUNT(*) FROM captchas GROUP BY status`)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetCaptchaStats")
+    }
+    defer rows.Close()
+    stats := make(map[string]int)
+    for rows.Next() {
+        var status string
+        var count int
+        if err := rows.Scan(&status, &count); err != nil {
+            return stats, helpers.WrapErr(err, "cant GetCaptchaStats rows")
+        }
+        stats[status] = count
+    }
+    return stats, nil
+}
+
+func scanCaptchas(rows *sql.Rows) ([]storage.Captcha, error) {
+    defer rows.Close()
+    var captchas []storage.Captcha
+    for rows.Next() {
+        var captcha storage.Captcha
+        err := rows.Scan(
+            &captcha.Id,
+            &captcha.UserId,
+            &captcha.ChatId,
+            &captcha.UserChatId,
+            &captcha.Event,
+            &captcha.Answer,
+            &captcha.MessageId,
+            &captcha.Status,
+            &captcha.Deadline,
+            &captcha.CreatedAt,
+        )
+        if err != nil {
+            return captchas, err
+        }
+        captchas = append(captchas, captcha)
+    }
+    return captchas, rows.Err()
+}
@@ -0,0 +1,133 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "strconv"
+    "strings"
+    "time"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+// SaveDelivery keeps only the latest result of sending a broadcast to the user
+func (s *Storage) SaveDelivery(ctx context.Context, delivery storage.Delivery) error {
+    messageIds, err := json.Marshal(delivery.MessageIds)
+    if err != nil {
+        return helpers.WrapErr(err, "cant marshal message ids of delivery for user " + strconv.Itoa(delivery.UserId))
+    }
+    query := `INSERT OR REPLACE INTO deliveries (broadcast_id, user_id, status, error, message_id, message_ids, date_create) 
+        VALUES (?, ?, ?, ?, ?, ?, ?)`
+    _, err = s.db.ExecContext(
+        ctx,
+        query,
+        delivery.BroadcastId,
+        delivery.UserId,
+        delivery.Status,
+        delivery.Error,
+        delivery.MessageId,
+        string(messageIds),
+        delivery.Timestamp,
+    )
+    if err != nil {
+        return helpers.WrapErr(err, "cant SaveDelivery for user " + strconv.Itoa(delivery.UserId))
+    }
+    return nil
+}
+
+// GetDeliveries returns deliveries of the broadcast with one of statuses, all of them if statuses are empty
+func (s *Storage) GetDeliveries(ctx context.Context, broadcastId int, statuses []string, limit int) ([]storage.Delivery, error) {
+    query := `SELECT broadcast_id, user_id, status, error, message_id, message_ids, date_create FROM deliveries WHERE broadcast_id = ?`
+    args := []interface{}{broadcastId}
+    if len(statuses) > 0 {
+        placeholders := make([]string, len(statuses))
+        for i, status := range statuses {
+            placeholders[i] = "?"
+            args = append(args, status)
+        }
+        query += " and status IN (" + strings.Join(placeholders, ",") + ")"
+    }
+    query += " ORDER BY date_create DESC LIMIT ?"
+    args = append(args, limit)
+
+    rows, err := s.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetDeliveries broadcast " + strconv.Itoa(broadcastId))
+    }
+    deliveries, err := scanDeliveries(rows)
+    if err != nil {
+        return deliveries, helpers.WrapErr(err, "cant GetDeliveries rows")
+    }
+    return deliveries, nil
+}
+
+func (s *Storage) GetUserDeliveries(ctx context.Context, userId int) ([]storage.Delivery, error) {
+    query := `SELECT broadcast_id, user_id, status, error, message_id, message_ids, date_create FROM deliveries 
+        WHERE user_id = ? ORDER BY date_create DESC`
+    rows, err := s.db.QueryContext(ctx, query, userId)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetUserDeliveries user " + strconv.Itoa(userId))
+    }
+    deliveries, err := scanDeliveries(rows)
+    if err != nil {
+        return deliveries, helpers.WrapErr(err, "cant GetUserDeliveries rows")
+    }
+    return deliveries, nil
+}
+
+func (s *Storage) GetDeliveryStats(ctx context.Context, broadcastId int) (map[string]int, error) {
+    stats := make(map[string]int)
+    query := `SELECT status, COUNT(*) FROM deliveries WHERE broadcast_id = ? GROUP BY status`
+    rows, err := s.db.QueryContext(ctx, query, broadcastId)
+    if err != nil {
+        return stats, helpers.WrapErr(err, "cant GetDeliveryStats broadcast " + strconv.Itoa(broadcastId))
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var status string
+        var count int
+        if err := rows.Scan(&status, &count); err != nil {
+            return stats, helpers.WrapErr(err, "cant GetDeliveryStats rows")
+        }
+        stats[status] = count
+    }
+    return stats, nil
+}
+
+// GetCountDeliveriesSince counts users who got a result of the broadcast after the time, it shows the current speed
+func (s *Storage) GetCountDeliveriesSince(ctx context.Context, broadcastId int, since time.Time) (int, error) {
+    query := `SELECT COUNT(*) FROM deliveries WHERE broadcast_id = ? and date_create >= ?`
+    var count int
+    if err := s.db.QueryRowContext(ctx, query, broadcastId, since).Scan(&count); err != nil {
+        return 0, helpers.WrapErr(err, "cant GetCountDeliveriesSince broadcast " + strconv.Itoa(broadcastId))
+    }
+    return count, nil
+}
+
+func scanDeliveries(rows *sql.Rows) ([]storage.Delivery, error) {
+    defer rows.Close()
+    var deliveries []storage.Delivery
+    for rows.Next() {
+        var delivery storage.Delivery
+        var messageIds string
+        err := rows.Scan(
+            &delivery.BroadcastId,
+            &delivery.UserId,
+            &delivery.Status,
+            &delivery.Error,
+            &delivery.MessageId,
+            &messageIds,
+            &delivery.Timestamp,
+        )
+        if err != nil {
+            return deliveries, err
+        }
+        // deliveries of older versions have only the first message
+        if err := json.Unmarshal([]byte(messageIds), &delivery.MessageIds); err != nil || len(delivery.MessageIds) == 0 {
+            delivery.MessageIds = []int{delivery.MessageId}
+        }
+        deliveries = append(deliveries, delivery)
+    }
+    return deliveries, rows.Err()
+}
@@ -0,0 +1,247 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "strconv"
+    "time"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+const outboxColumns = `id, chat_id, kind, payload, priority, status, attempts, last_error, next_attempt_at, date_create, source, source_id`
+
+func (s *Storage) EnqueueOutbox(ctx context.Context, item storage.OutboxItem) error {
+    nextAttemptAt := item.NextAttemptAt
+    if nextAttemptAt.IsZero() {
+        nextAttemptAt = time.Now()
+    }
+    query := `INSERT INTO outbox (chat_id, kind, payload, priority, status, next_attempt_at, date_create, source, source_id) 
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+    _, err := s.db.ExecContext(
+        ctx,
+        query,
+        item.ChatId,
+        item.Kind,
+        item.Payload,
+        item.Priority,
+        storage.OutboxStatusPending,
+        nextAttemptAt,
+        time.Now(),
+        item.Source,
+        item.SourceId,
+    )
+    if err != nil {
+        return helpers.WrapErr(err, "cant EnqueueOutbox for chat " + strconv.Itoa(item.ChatId))
+    }
+    return nil
+}
+
+// EnqueueOutboxBatch adds all items in one transaction
+func (s *Storage) EnqueueOutboxBatch(ctx context.Context, items []storage.OutboxItem) error {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return helpers.WrapErr(err, "cant EnqueueOutboxBatch begin tx")
+    }
+    defer tx.Rollback()
+
+    query := `INSERT INTO outbox (chat_id, kind, payload, priority, status, next_attempt_at, date_create, source, source_id) 
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+    now := time.Now()
+    for _, item := range items {
+        nextAttemptAt := item.NextAttemptAt
+        if nextAttemptAt.IsZero() {
+            nextAttemptAt = now
+        }
+        _, err := tx.ExecContext(
+            ctx,
+            query,
+            item.ChatId,
+            item.Kind,
+            item.Payload,
+            item.Priority,
+            storage.OutboxStatusPending,
+            nextAttemptAt,
+            now,
+            item.Source,
+            item.SourceId,
+        )
+        if err != nil {
+            return helpers.WrapErr(err, "cant EnqueueOutboxBatch for chat " + strconv.Itoa(item.ChatId))
+        }
+    }
+    return helpers.WrapErr(tx.Commit(), "cant EnqueueOutboxBatch commit")
+}
+
+// ClaimOutbox marks due pending items as sending so nobody else takes them, most important first
+func (s *Storage) ClaimOutbox(ctx context.Context, limit int) ([]storage.OutboxItem, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant ClaimOutbox begin tx")
+    }
+    defer tx.Rollback()
+
+    query := `SELECT ` + outboxColumns + ` FROM outbox WHERE status = ? and next_attempt_at <= ? 
+        ORDER BY priority, id LIMIT ?`
+    rows, err := tx.QueryContext(ctx, query, storage.OutboxStatusPending, time.Now(), limit)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant ClaimOutbox select")
+    }
+    items, err := scanOutboxItems(rows)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant ClaimOutbox rows")
+    }
+
+    update := `UPDATE outbox SET status = ? WHERE id = ?`
+    for i := range items {
+        if _, err := tx.ExecContext(ctx, update, storage.OutboxStatusSending, items[i].Id); err != nil {
+            return nil, helpers.WrapErr(err, "cant ClaimOutbox update")
+        }
+        items[i].Status = storage.OutboxStatusSending
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, helpers.WrapErr(err, "cant ClaimOutbox commit")
+    }
+    return items, nil
+}
+
+func (s *Storage) MarkOutboxSent(ctx context.Context, id int) error {
+    query := `UPDATE outbox SET status = ?, attempts = attempts + 1, last_error = "" WHERE id = ?`
+    _, err := s.db.ExecContext(ctx, query, storage.OutboxStatusSent, id)
+    if err != nil {
+        return helpers.WrapErr(err, "cant MarkOutboxSent id " + strconv.Itoa(id))
+    }
+    return nil
+}
+
+func (s *Storage) RescheduleOutbox(ctx context.Context, id int, lastError string, nextAttemptAt time.Time) error {
+    query := `UPDATE outbox SET status = ?, attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?`
+    _, err := s.db.ExecContext(ctx, query, storage.OutboxStatusPending, lastError, nextAttemptAt, id)
+    if err != nil {
+        return helpers.WrapErr(err, "cant RescheduleOutbox id " + strconv.Itoa(id))
+    }
+    return nil
+}
+
+func (s *Storage) MarkOutboxFailed(ctx context.Context, id int, lastError string) error {
+    query := `UPDATE outbox SET status = ?, attempts = attempts + 1, last_error = ? WHERE id = ?`
+    _, err := s.db.ExecContext(ctx, query, storage.OutboxStatusFailed, lastError, id)
+    if err != nil {
+        return helpers.WrapErr(err, "cant MarkOutboxFailed id " + strconv.Itoa(id))
+    }
+    return nil
+}
+
+// ResetSendingOutbox returns items claimed before a restart back to the queue
+func (s *Storage) ResetSendingOutbox(ctx context.Context) error {
+    query := `UPDATE outbox SET status = ? WHERE status = ?`
+    _, err := s.db.ExecContext(ctx, query, storage.OutboxStatusPending, storage.OutboxStatusSending)
+    if err != nil {
+        return helpers.WrapErr(err, "cant ResetSendingOutbox")
+    }
+    return nil
+}
+
+// RetryFailedOutbox queues failed items again, messages of paused and cancelled campaigns stay failed
+func (s *Storage) RetryFailedOutbox(ctx context.Context) (int, error) {
+    query := `UPDATE outbox SET status = ?, attempts = 0, next_attempt_at = ? WHERE status = ? AND NOT (source = ? AND EXISTS (
+        SELECT 1 FROM campaigns WHERE campaigns.id = outbox.source_id AND campaigns.status IN (?, ?)))`
+    res, err := s.db.ExecContext(
+        ctx,
+        query,
+        storage.OutboxStatusPending,
+        time.Now(),
+        storage.OutboxStatusFailed,
+        storage.OutboxSourceBroadcast,
+        storage.CampaignStatusPaused,
+        storage.CampaignStatusCancelled,
+    )
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant RetryFailedOutbox")
+    }
+    count, err := res.RowsAffected()
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant RetryFailedOutbox RowsAffected")
+    }
+    return int(count), nil
+}
+
+func (s *Storage) GetOutboxStats(ctx context.Context) (map[string]int, error) {
+    stats := make(map[string]int)
+    query := `SELECT status, COUNT(*) FROM outbox GROUP BY status`
+    rows, err := s.db.QueryContext(ctx, query)
+    if err != nil {
+        return stats, helpers.WrapErr(err, "cant GetOutboxStats")
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var status string
+        var count int
+        if err := rows.Scan(&status, &count); err != nil {
+            return stats, helpers.WrapErr(err, "cant GetOutboxStats rows")
+        }
+        stats[status] = count
+    }
+    return stats, nil
+}
+
+// GetOutboxSourceStats counts messages of one broadcast or another source by status
+func (s *Storage) GetOutboxSourceStats(ctx context.Context, source string, sourceId int) (map[string]int, error) {
+    stats := make(map[string]int)
+    query := `SELECT status, COUNT(*) FROM outbox WHERE source = ? and source_id = ? GROUP BY status`
+    rows, err := s.db.QueryContext(ctx, query, source, sourceId)
+    if err != nil {
+        return stats, helpers.WrapErr(err, "cant GetOutboxSourceStats " + source)
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var status string
+        var count int
+        if err := rows.Scan(&status, &count); err != nil {
+            return stats, helpers.WrapErr(err, "cant GetOutboxSourceStats rows")
+        }
+        stats[status] = count
+    }
+    return stats, nil
+}
+
+func (s *Storage) GetFailedOutbox(ctx context.Context, limit int) ([]storage.OutboxItem, error) {
+    query := `SELECT ` + outboxColumns + ` FROM outbox WHERE status = ? ORDER BY id DESC LIMIT ?`
+    rows, err := s.db.QueryContext(ctx, query, storage.OutboxStatusFailed, limit)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetFailedOutbox")
+    }
+    items, err := scanOutboxItems(rows)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetFailedOutbox rows")
+    }
+    return items, nil
+}
+
+func scanOutboxItems(rows *sql.Rows) ([]storage.OutboxItem, error) {
+    defer rows.Close()
+    var items []storage.OutboxItem
+    for rows.Next() {
+        var item storage.OutboxItem
+        err := rows.Scan(
+            &item.Id,
+            &item.ChatId,
+            &item.Kind,
+            &item.Payload,
+            &item.Priority,
+            &item.Status,
+            &item.Attempts,
+            &item.LastError,
+            &item.NextAttemptAt,
+            &item.CreatedAt,
+            &item.Source,
+            &item.SourceId,
+        )
+        if err != nil {
+            return items, err
+        }
+        items = append(items, item)
+    }
+    return items, rows.Err()
+}
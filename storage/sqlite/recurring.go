@@ -0,0 +1,162 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "strconv"
+    "time"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+const recurringBroadcastColumns = `id, campaign_id, schedule, next_run, last_run, paused, date_create`
+
+func (s *Storage) CreateRecurringBroadcast(ctx context.Context, recurring storage.RecurringBroadcast) (int, error) {
+    query := `INSERT INTO recurring_broadcasts (campaign_id, schedule, next_run, paused, date_create) VALUES (?, ?, ?, ?, ?)`
+    res, err := s.db.ExecContext(ctx, query, recurring.CampaignId, recurring.Schedule, recurring.NextRun, recurring.Paused, time.Now())
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant CreateRecurringBroadcast for campaign " + strconv.Itoa(recurring.CampaignId))
+    }
+    id, err := res.LastInsertId()
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant CreateRecurringBroadcast LastInsertId")
+    }
+    return int(id), nil
+}
+
+func (s *Storage) GetRecurringBroadcast(ctx context.Context, id int) (storage.RecurringBroadcast, error) {
+    query := `SELECT ` + recurringBroadcastColumns + ` FROM recurring_broadcasts WHERE id = ?`
+    rows, err := s.db.QueryContext(ctx, query, id)
+    if err != nil {
+        return storage.RecurringBroadcast{}, helpers.WrapErr(err, "cant GetRecurringBroadcast " + strconv.Itoa(id))
+    }
+    recurring, err := scanRecurringBroadcasts(rows)
+    if err != nil {
+        return storage.RecurringBroadcast{}, helpers.WrapErr(err, "cant GetRecurringBroadcast rows")
+    }
+    if len(recurring) == 0 {
+        return storage.RecurringBroadcast{}, helpers.WrapErr(sql.ErrNoRows, "cant GetRecurringBroadcast " + strconv.Itoa(id))
+    }
+    return recurring[0], nil
+}
+
+func (s *Storage) GetRecurringBroadcasts(ctx context.Context) ([]storage.RecurringBroadcast, error) {
+    query := `SELECT ` + recurringBroadcastColumns + ` FROM recurring_broadcasts ORDER BY id`
+    rows, err := s.db.QueryContext(ctx, query)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetRecurringBroadcasts")
+    }
+    recurring, err := scanRecurringBroadcasts(rows)
+    if err != nil {
+        return recurring, helpers.WrapErr(err, "cant GetRecurringBroadcasts rows")
+    }
+    return recurring, nil
+}
+
+// GetDueRecurringBroadcasts returns not paused recurring broadcasts whose next run has come
+func (s *Storage) GetDueRecurringBroadcasts(ctx context.Context) ([]storage.RecurringBroadcast, error) {
+    query := `SELECT ` + recurringBroadcastColumns + ` FROM recurring_broadcasts 
+        WHERE paused = false and next_run != 0 and next_run <= ? ORDER BY next_run, id`
+    rows, err := s.db.QueryContext(ctx, query, time.Now())
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetDueRecurringBroadcasts")
+    }
+    recurring, err := scanRecurringBroadcasts(rows)
+    if err != nil {
+        return recurring, helpers.WrapErr(err, "cant GetDueRecurringBroadcasts rows")
+    }
+    return recurring, nil
+}
+
+// StartRecurringBroadcast creates the campaign for this run and moves the schedule to the next run in one transaction,
+// so a run is never started twice. It returns 0 if the run was already started
+func (s *Storage) StartRecurringBroadcast(ctx context.Context, recurring storage.RecurringBroadcast, campaign storage.Campaign, nextRun time.Time) (int, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant StartRecurringBroadcast begin tx")
+    }
+    defer tx.Rollback()
+
+    now := time.Now()
+    query := `UPDATE recurring_broadcasts SET next_run = ?, last_run = ? WHERE id = ? and next_run = ? and paused = false`
+    res, err := tx.ExecContext(ctx, query, nextRun, now, recurring.Id, recurring.NextRun)
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant StartRecurringBroadcast update " + strconv.Itoa(recurring.Id))
+    }
+    updated, err := res.RowsAffected()
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant StartRecurringBroadcast RowsAffected")
+    }
+    if updated == 0 {
+        return 0, nil
+    }
+
+    insert := `INSERT INTO campaigns (name, from_chat_id, message_id, time_for_sent, audience, status, created_by, date_create, 
+        progress_chat_id, ttl_seconds, content) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+    audience, err := marshalAudience(campaign.Audience)
+    if err != nil {
+        return 0, err
+    }
+    res, err = tx.ExecContext(
+        ctx,
+        insert,
+        campaign.Name,
+        campaign.FromChatId,
+        campaign.MessageId,
+        campaignTimeToSent(campaign),
+        audience,
+        campaign.Status,
+        campaign.CreatedBy,
+        now,
+        campaign.ProgressChatId,
+        int(campaign.TTL.Seconds()),
+        campaign.Content,
+    )
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant StartRecurringBroadcast create campaign")
+    }
+    id, err := res.LastInsertId()
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant StartRecurringBroadcast LastInsertId")
+    }
+    return int(id), helpers.WrapErr(tx.Commit(), "cant StartRecurringBroadcast commit")
+}
+
+// SetRecurringBroadcastPaused pauses or resumes the recurring broadcast, a resumed one runs at nextRun
+func (s *Storage) SetRecurringBroadcastPaused(ctx context.Context, id int, paused bool, nextRun time.Time) error {
+    query := `UPDATE recurring_broadcasts SET paused = ?, next_run = ? WHERE id = ?`
+    if _, err := s.db.ExecContext(ctx, query, paused, nextRun, id); err != nil {
+        return helpers.WrapErr(err, "cant SetRecurringBroadcastPaused " + strconv.Itoa(id))
+    }
+    return nil
+}
+
+func (s *Storage) DeleteRecurringBroadcast(ctx context.Context, id int) error {
+    query := `DELETE FROM recurring_broadcasts WHERE id = ?`
+    if _, err := s.db.ExecContext(ctx, query, id); err != nil {
+        return helpers.WrapErr(err, "cant DeleteRecurringBroadcast " + strconv.Itoa(id))
+    }
+    return nil
+}
+
+func scanRecurringBroadcasts(rows *sql.Rows) ([]storage.RecurringBroadcast, error) {
+    defer rows.Close()
+    var recurring []storage.RecurringBroadcast
+    for rows.Next() {
+        var item storage.RecurringBroadcast
+        err := rows.Scan(
+            &item.Id,
+            &item.CampaignId,
+            &item.Schedule,
+            &item.NextRun,
+            &item.LastRun,
+            &item.Paused,
+            &item.CreatedAt,
+        )
+        if err != nil {
+            return recurring, err
+        }
+        recurring = append(recurring, item)
+    }
+    return recurring, rows.Err()
+}
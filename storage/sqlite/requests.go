@@ -0,0 +1,122 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "strconv"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+// GetPendingRequestsToJoin returns requests waiting for an admin decision in the order they came
+func (s *Storage) GetPendingRequestsToJoin(ctx context.Context, offset int, limit int) ([]storage.RequestToJoin, error) {
+    query := `SELECT rowid, event_request_to_join FROM requests_to_join WHERE auto_accept_status = ? AND decided_by = 0 ORDER BY rowid LIMIT ? OFFSET ?`
+    rows, err := s.db.QueryContext(ctx, query, false, limit, offset)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant select GetPendingRequestsToJoin")
+    }
+    defer rows.Close()
+    var requests []storage.RequestToJoin
+    for rows.Next() {
+        var request storage.RequestToJoin
+        if err := rows.Scan(&request.Id, &request.Event); err != nil {
+            return requests, helpers.WrapErr(err, "cant GetPendingRequestsToJoin rows")
+        }
+        requests = append(requests, request)
+    }
+    return requests, nil
+}
+
+func (s *Storage) GetCountPendingRequestsToJoin(ctx context.Context) (int, error) {
+    query := `SELECT COUNT(*) FROM requests_to_join WHERE auto_accept_status = ? AND decided_by = 0`
+    var count int
+    if err := s.db.QueryRowContext(ctx, query, false).Scan(&count); err != nil {
+        return 0, helpers.WrapErr(err, "cant GetCountPendingRequestsToJoin")
+    }
+    return count, nil
+}
+
+// GetRequestToJoin tells whether the request is still saved
+func (s *Storage) GetRequestToJoin(ctx context.Context, id int) (storage.RequestToJoin, bool, error) {
+    query := `SELECT rowid, event_request_to_join FROM requests_to_join WHERE rowid = ?`
+    var request storage.RequestToJoin
+    err := s.db.QueryRowContext(ctx, query, id).Scan(&request.Id, &request.Event)
+    if err == sql.ErrNoRows {
+        return storage.RequestToJoin{}, false, nil
+    }
+    if err != nil {
+        return storage.RequestToJoin{}, false, helpers.WrapErr(err, "cant GetRequestToJoin " + strconv.Itoa(id))
+    }
+    return request, true, nil
+}
+
+// ClaimRequestToJoin marks the request as being decided by the admin, only one admin can claim it,
+// false means another admin has claimed it or it was already handled
+func (s *Storage) ClaimRequestToJoin(ctx context.Context, id int, adminId int) (storage.RequestToJoin, bool, error) {
+    res, err := s.db.ExecContext(ctx, `UPDATE requests_to_join SET decided_by = ? WHERE rowid = ? AND decided_by = 0`, adminId, id)
+    if err != nil {
+        return storage.RequestToJoin{}, false, helpers.WrapErr(err, "cant ClaimRequestToJoin " + strconv.Itoa(id))
+    }
+    affected, err := res.RowsAffected()
+    if err != nil {
+        return storage.RequestToJoin{}, false, helpers.WrapErr(err, "cant ClaimRequestToJoin RowsAffected")
+    }
+    if affected == 0 {
+        return storage.RequestToJoin{}, false, nil
+    }
+    request := storage.RequestToJoin{Id: id}
+    query := `SELECT event_request_to_join FROM requests_to_join WHERE rowid = ?`
+    if err := s.db.QueryRowContext(ctx, query, id).Scan(&request.Event); err != nil {
+        return storage.RequestToJoin{}, false, helpers.WrapErr(err, "cant ClaimRequestToJoin select " + strconv.Itoa(id))
+    }
+    return request, true, nil
+}
+
+// ReleaseRequestToJoin returns the claimed request to the queue when the decision failed
+func (s *Storage) ReleaseRequestToJoin(ctx context.Context, id int) error {
+    if _, err := s.db.ExecContext(ctx, `UPDATE requests_to_join SET decided_by = 0 WHERE rowid = ?`, id); err != nil {
+        return helpers.WrapErr(err, "cant ReleaseRequestToJoin " + strconv.Itoa(id))
+    }
+    return nil
+}
+
+func (s *Storage) DeleteRequestToJoin(ctx context.Context, id int) error {
+    query := `DELETE FROM requests_to_join WHERE rowid = ?`
+    if _, err := s.db.ExecContext(ctx, query, id); err != nil {
+        return helpers.WrapErr(err, "cant DeleteRequestToJoin " + strconv.Itoa(id))
+    }
+    return nil
+}
+
+func (s *Storage) SaveRequestCard(ctx context.Context, card storage.RequestCard) error {
+    query := `INSERT INTO request_cards (request_id, chat_id, message_id) VALUES (?, ?, ?)`
+    if _, err := s.db.ExecContext(ctx, query, card.RequestId, card.ChatId, card.MessageId); err != nil {
+        return helpers.WrapErr(err, "cant SaveRequestCard " + strconv.Itoa(card.RequestId))
+    }
+    return nil
+}
+
+func (s *Storage) GetRequestCards(ctx context.Context, requestId int) ([]storage.RequestCard, error) {
+    query := `SELECT request_id, chat_id, message_id FROM request_cards WHERE request_id = ?`
+    rows, err := s.db.QueryContext(ctx, query, requestId)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetRequestCards " + strconv.Itoa(requestId))
+    }
+    defer rows.Close()
+    var cards []storage.RequestCard
+    for rows.Next() {
+        var card storage.RequestCard
+        if err := rows.Scan(&card.RequestId, &card.ChatId, &card.MessageId); err != nil {
+            return cards, helpers.WrapErr(err, "cant GetRequestCards rows")
+        }
+        cards = append(cards, card)
+    }
+    return cards, nil
+}
+
+func (s *Storage) DeleteRequestCards(ctx context.Context, requestId int) error {
+    if _, err := s.db.ExecContext(ctx, `DELETE FROM request_cards WHERE request_id = ?`, requestId); err != nil {
+        return helpers.WrapErr(err, "cant DeleteRequestCards " + strconv.Itoa(requestId))
+    }
+    return nil
+}
@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+    "context"
+    "strconv"
+    "time"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+const joinDecisionColumns = `id, user_id, chat_id, username, request_date, decision, rule, date_create`
+
+func (s *Storage) CreateJoinRule(ctx context.Context, rule storage.JoinRule) (int, error) {
+    query := `INSERT INTO join_rules (kind, value, date_create) VALUES (?, ?, ?)`
+    res, err := s.db.ExecContext(ctx, query, rule.Kind, rule.Value, time.Now())
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant CreateJoinRule " + rule.Kind)
+    }
+    id, err := res.LastInsertId()
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant CreateJoinRule LastInsertId")
+    }
+    return int(id), nil
+}
+
+func (s *Storage) GetJoinRules(ctx context.Context) ([]storage.JoinRule, error) {
+    rows, err := s.db.QueryContext(ctx, `SELECT id, kind, value, date_create FROM join_rules ORDER BY id`)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetJoinRules")
+    }
+    defer rows.Close()
+    var rules []storage.JoinRule
+    for rows.Next() {
+        var rule storage.JoinRule
+        if err := rows.Scan(&rule.Id, &rule.Kind, &rule.Value, &rule.CreatedAt); err != nil {
+            return rules, helpers.WrapErr(err, "cant GetJoinRules rows")
+        }
+        rules = append(rules, rule)
+    }
+    return rules, helpers.WrapErr(rows.Err(), "cant GetJoinRules rows")
+}
+
+func (s *Storage) DeleteJoinRule(ctx context.Context, id int) error {
+    if _, err := s.db.ExecContext(ctx, `DELETE FROM join_rules WHERE id = ?`, id); err != nil {
+        return helpers.WrapErr(err, "cant DeleteJoinRule " + strconv.Itoa(id))
+    }
+    return nil
+}
+
+func (s *Storage) LogJoinDecision(ctx context.Context, decision storage.JoinDecision) error {
+    query := `INSERT INTO join_decisions (user_id, chat_id, username, request_date, decision, rule, date_create) 
+        VALUES (?, ?, ?, ?, ?, ?, ?)`
+    _, err := s.db.ExecContext(
+        ctx,
+        query,
+        decision.UserId,
+        decision.ChatId,
+        decision.Username,
+        decision.RequestDate,
+        decision.Decision,
+        decision.Rule,
+        time.Now(),
+    )
+    return helpers.WrapErr(err, "cant LogJoinDecision userId: " + strconv.Itoa(decision.UserId))
+}
+
+// CountJoinDecisions counts requests to join of the user logged since the time except the request sent at exceptRequestDate
+func (s *Storage) CountJoinDecisions(ctx context.Context, userId int, since time.Time, exceptRequestDate int) (int, error) {
+    var count int
+    query := `SELECT COUNT(*) FROM join_decisions WHERE user_id = ? AND date_create >= ? AND request_date != ?`
+    err := s.db.QueryRowContext(ctx, query, userId, since, exceptRequestDate).Scan(&count)
+    return count, helpers.WrapErr(err, "cant CountJoinDecisions userId: " + strconv.Itoa(userId))
+}
+
+func (s *Storage) DeleteJoinDecisionsBefore(ctx context.Context, before time.Time) error {
+    _, err := s.db.ExecContext(ctx, `DELETE FROM join_decisions WHERE date_create < ?`, before)
+    return helpers.WrapErr(err, "cant DeleteJoinDecisionsBefore")
+}
+
+// GetJoinDecisions returns the latest decisions first
+func (s *Storage) GetJoinDecisions(ctx context.Context, decision string, limit int) ([]storage.JoinDecision, error) {
+    query := `SELECT ` + joinDecisionColumns + ` FROM join_decisions WHERE decision = ? ORDER BY id DESC LIMIT ?`
+    rows, err := s.db.QueryContext(ctx, query, decision, limit)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetJoinDecisions")
+    }
+    defer rows.Close()
+    var decisions []storage.JoinDecision
+    for rows.Next() {
+        var item storage.JoinDecision
+        err := rows.Scan(&item.Id, &item.UserId, &item.ChatId, &item.Username, &item.RequestDate, &item.Decision, &item.Rule, &item.CreatedAt)
+        if err != nil {
+            return decisions, helpers.WrapErr(err, "cant GetJoinDecisions rows")
+        }
+        decisions = append(decisions, item)
+    }
+    return decisions, helpers.WrapErr(rows.Err(), "cant GetJoinDecisions rows")
+}
@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "strconv"
+    "time"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+// SaveScreening keeps the state of the questionnaire of the request to join
+func (s *Storage) SaveScreening(ctx context.Context, screening storage.Screening) error {
+    answers, err := json.Marshal(screening.Answers)
+    if err != nil {
+        return helpers.WrapErr(err, "cant marshal screening answers")
+    }
+    createdAt := screening.CreatedAt
+    if createdAt.IsZero() {
+        createdAt = time.Now()
+    }
+    query := `INSERT OR REPLACE INTO screenings (user_id, chat_id, user_chat_id, request_id, step, status, answers, date_create, date_update) 
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+    _, err = s.db.ExecContext(
+        ctx,
+        query,
+        screening.UserId,
+        screening.ChatId,
+        screening.UserChatId,
+        screening.RequestId,
+        screening.Step,
+        screening.Status,
+        string(answers),
+        createdAt,
+        time.Now(),
+    )
+    if err != nil {
+        return helpers.WrapErr(err, "cant SaveScreening " + strconv.Itoa(screening.RequestId))
+    }
+    return nil
+}
+
+// GetScreening returns the questionnaire of the request to join
+func (s *Storage) GetScreening(ctx context.Context, requestId int) (storage.Screening, bool, error) {
+    query := `SELECT user_id, chat_id, user_chat_id, request_id, step, status, answers, date_create, date_update 
+        FROM screenings WHERE request_id = ?`
+    screening, found, err := scanScreening(s.db.QueryRowContext(ctx, query, requestId))
+    if err != nil {
+        return storage.Screening{}, false, helpers.WrapErr(err, "cant GetScreening " + strconv.Itoa(requestId))
+    }
+    return screening, found, nil
+}
+
+// GetAskingScreening returns the last questionnaire the user is answering now
+func (s *Storage) GetAskingScreening(ctx context.Context, userId int) (storage.Screening, bool, error) {
+    query := `SELECT user_id, chat_id, user_chat_id, request_id, step, status, answers, date_create, date_update 
+        FROM screenings WHERE user_id = ? AND status = ? ORDER BY request_id DESC LIMIT 1`
+    screening, found, err := scanScreening(s.db.QueryRowContext(ctx, query, userId, storage.ScreeningStatusAsking))
+    if err != nil {
+        return storage.Screening{}, false, helpers.WrapErr(err, "cant GetAskingScreening " + strconv.Itoa(userId))
+    }
+    return screening, found, nil
+}
+
+func scanScreening(row *sql.Row) (storage.Screening, bool, error) {
+    var screening storage.Screening
+    var answers string
+    err := row.Scan(
+        &screening.UserId,
+        &screening.ChatId,
+        &screening.UserChatId,
+        &screening.RequestId,
+        &screening.Step,
+        &screening.Status,
+        &answers,
+        &screening.CreatedAt,
+        &screening.UpdatedAt,
+    )
+    if err == sql.ErrNoRows {
+        return storage.Screening{}, false, nil
+    }
+    if err != nil {
+        return storage.Screening{}, false, err
+    }
+    if err := json.Unmarshal([]byte(answers), &screening.Answers); err != nil {
+        return storage.Screening{}, false, helpers.WrapErr(err, "cant unmarshal screening answers")
+    }
+    return screening, true, nil
+}
@@ -29,6 +29,9 @@ func New(path string) (*Storage, error) {
         return nil, helpers.WrapErr(errping, "cant ping db")
     }
 
+    // sqlite allows only one writer, background workers would get "database is locked" with a pool
+    db.SetMaxOpenConns(1)
+
     return &Storage{db: db}, nil
 }
 
@@ -88,7 +91,7 @@ func (s *Storage) GetUser(ctx context.Context, id int) (storage.User, error) {
         var leavedChannelsStr []string
         json.Unmarshal(channelsId, &channelsIdStr)
         json.Unmarshal(leavedChannels, &leavedChannelsStr)
-        user := storage.User{
+        user = storage.User{
             Id: id,
             Timestamp: time,
             FirstName: firstName,
@@ -242,13 +245,38 @@ func (s *Storage) GetCountUsersWithLastMsgId(ctx context.Context, lastMessageId
 }
 
 func (s *Storage) GetAllUsers(ctx context.Context) ([]storage.User, error) {
-    var users []storage.User
     query := `SELECT id, date_create, first_name, last_name, username, channels, COALESCE(last_message_sent, 0), leaved_channels FROM users`
     rows, err := s.db.QueryContext(ctx, query)
     if err != nil {
-        return users, helpers.WrapErr(err, "cant GetAllUsers")
+        return nil, helpers.WrapErr(err, "cant GetAllUsers")
+    }
+    users, err := scanUsers(rows)
+    if err != nil {
+        return users, helpers.WrapErr(err, "cant GetAllUsers rows")
     }
+    return users, nil
+}
+
+// GetUsersAfter returns the next batch of users of the audience ordered by id, it lets to walk through users in stable order
+func (s *Storage) GetUsersAfter(ctx context.Context, audience storage.Audience, lastUserId int, limit int) ([]storage.User, error) {
+    where, args := audienceFilter(audience)
+    query := `SELECT id, date_create, first_name, last_name, username, channels, COALESCE(last_message_sent, 0), leaved_channels 
+        FROM users WHERE id > ? AND ` + where + ` ORDER BY id LIMIT ?`
+    args = append([]interface{}{lastUserId}, args...)
+    rows, err := s.db.QueryContext(ctx, query, append(args, limit)...)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetUsersAfter")
+    }
+    users, err := scanUsers(rows)
+    if err != nil {
+        return users, helpers.WrapErr(err, "cant GetUsersAfter rows")
+    }
+    return users, nil
+}
+
+func scanUsers(rows *sql.Rows) ([]storage.User, error) {
     defer rows.Close()
+    var users []storage.User
     for rows.Next() {
         var id int
         var time time.Time
@@ -268,11 +296,14 @@ func (s *Storage) GetAllUsers(ctx context.Context) ([]storage.User, error) {
             &lastMessage,
             &leavedChannels,
         )
+        if err != nil {
+            return users, err
+        }
         var channelsIdStr []string
         var leavedChannelsStr []string
         json.Unmarshal(channelsId, &channelsIdStr)
         json.Unmarshal(leavedChannels, &leavedChannelsStr)
-        user := storage.User{
+        users = append(users, storage.User{
             Id: id,
             Timestamp: time,
             FirstName: firstName,
@@ -281,16 +312,12 @@ func (s *Storage) GetAllUsers(ctx context.Context) ([]storage.User, error) {
             ChannelsIds: channelsIdStr,
             LastMessageId: lastMessage,
             LeavedChannelsIds: leavedChannelsStr,
-        }
-        if err != nil {
-            return users, helpers.WrapErr(err, "cant GetAllUsers rows")
-        }
-        users = append(users, user)
+        })
     }
-    return users, nil
+    return users, rows.Err()
 }
 
-func (s *Storage) SaveMessage(ctx context.Context, messageId int, chatId int, key string) error {
+func (s *Storage) SaveMessage(ctx context.Context, messageId int, chatId int, content string, key string) error {
     remove_old_value := `DELETE FROM messages WHERE key = ?`
     _, err := s.db.ExecContext(
         ctx,
@@ -301,13 +328,14 @@ func (s *Storage) SaveMessage(ctx context.Context, messageId int, chatId int, ke
         return helpers.WrapErr(err, "cant remove old message with key:" + key)
     }
 
-    query := `INSERT INTO messages (key, forward_message_id, chat_id) VALUES(?, ?, ?)`
+    query := `INSERT INTO messages (key, forward_message_id, chat_id, content) VALUES(?, ?, ?, ?)`
     _, err = s.db.ExecContext(
         ctx,
         query,
         key,
         messageId,
         chatId,
+        content,
     )
     if err != nil {
         return helpers.WrapErr(err, "cant insert new message with key:" + key)
@@ -317,8 +345,8 @@ func (s *Storage) SaveMessage(ctx context.Context, messageId int, chatId int, ke
 
 func (s *Storage) GetCurrentMessage(ctx context.Context, key string) (storage.ForwardMessage, error) {
     var msg storage.ForwardMessage
-    query := `SELECT chat_id, forward_message_id, time_for_sent FROM messages WHERE key = ?`
-    if err := s.db.QueryRowContext(ctx, query, key).Scan(&msg.FromChatId, &msg.MessageId, &msg.TimeToSent); err != nil {
+    query := `SELECT chat_id, forward_message_id, time_for_sent, content FROM messages WHERE key = ?`
+    if err := s.db.QueryRowContext(ctx, query, key).Scan(&msg.FromChatId, &msg.MessageId, &msg.TimeToSent, &msg.Content); err != nil {
         return msg, helpers.WrapErr(err, "cant select text from message with key:" + key)
     }
     return msg, nil
@@ -342,8 +370,8 @@ func (s *Storage) GetMessageForSend(ctx context.Context, key string) (storage.Fo
     now := time.Now()
     var msg storage.ForwardMessage
     var timeForStart string
-    query := `SELECT chat_id, forward_message_id, time_for_sent FROM messages WHERE key = ? and time_for_sent <= ?`
-    if err := s.db.QueryRowContext(ctx, query, key, now).Scan(&msg.FromChatId, &msg.MessageId, &timeForStart); err != nil {
+    query := `SELECT chat_id, forward_message_id, time_for_sent, content FROM messages WHERE key = ? and time_for_sent <= ?`
+    if err := s.db.QueryRowContext(ctx, query, key, now).Scan(&msg.FromChatId, &msg.MessageId, &timeForStart, &msg.Content); err != nil {
         return msg, helpers.WrapErr(err, "GetMessageForSend cant select text from message with key:" + key)
     }
     if timeForStart != "" {
@@ -392,21 +420,27 @@ func (s *Storage) GetDelays(ctx context.Context, key string) (int, error) {
     return delay, nil
 }
 
-func (s *Storage) SaveDelayedEventRequestToJoin(ctx context.Context, data []byte, delay int, autoAcceptStatus bool) error {
+// SaveDelayedEventRequestToJoin returns id of the saved request
+func (s *Storage) SaveDelayedEventRequestToJoin(ctx context.Context, data []byte, delay int, autoAcceptStatus bool) (int, error) {
     now := time.Now()
     timeForAcceptedRequest := now.Add(time.Duration(delay) * time.Second)
-    query := `INSERT OR REPLACE INTO requests_to_join (event_request_to_join, date_sent_message, auto_accept_status) VALUES (?, ?, ?);`
-    _, err := s.db.ExecContext(
+    // the same event saved again keeps its id, cards and screenings of the request stay attached to it
+    query := `INSERT INTO requests_to_join (event_request_to_join, date_sent_message, auto_accept_status) VALUES (?, ?, ?) 
+        ON CONFLICT(event_request_to_join) DO UPDATE SET date_sent_message = excluded.date_sent_message, 
+        auto_accept_status = excluded.auto_accept_status 
+        RETURNING rowid;`
+    var id int
+    err := s.db.QueryRowContext(
         ctx,
         query,
         string(data),
         timeForAcceptedRequest,
         autoAcceptStatus,
-    )
+    ).Scan(&id)
     if err != nil {
-        return helpers.WrapErr(err, "cant SaveDelayedEventRequestToJoin")
+        return 0, helpers.WrapErr(err, "cant SaveDelayedEventRequestToJoin")
     }
-    return nil
+    return id, nil
 }
 
 func (s *Storage) DeleteDelayedEventRequestToJoin(ctx context.Context, data []byte) error {
@@ -424,7 +458,7 @@ func (s *Storage) DeleteDelayedEventRequestToJoin(ctx context.Context, data []by
 
 func (s *Storage) GetEventsWithDelayedMsgAfterRequestToJoin(ctx context.Context, autoAcceptStatus bool) ([]string, error) {
     now := time.Now()
-    query := `SELECT event_request_to_join from requests_to_join WHERE date_sent_message <= ? and auto_accept_status = ?`
+    query := `SELECT event_request_to_join from requests_to_join WHERE date_sent_message <= ? and auto_accept_status = ? and decided_by = 0`
     var eventsJson []string
 
     rows, err := s.db.QueryContext(ctx, query, now, autoAcceptStatus)
@@ -450,7 +484,46 @@ func (s *Storage) InitDbTables(ctx context.Context) error {
     messages := `CREATE TABLE IF NOT EXISTS messages (key text not null unique, forward_message_id int, chat_id int, time_for_sent timestamp default 0);`
     delays := `CREATE TABLE IF NOT EXISTS delays (key text not null unique, delay_seconds int);`
     requests_to_join := `CREATE TABLE IF NOT EXISTS requests_to_join (event_request_to_join json unique, date_sent_message timestamp default 0, auto_accept_status boolean default false);`
-    query := users + messages + delays + requests_to_join
+    outbox := `CREATE TABLE IF NOT EXISTS outbox (id integer primary key autoincrement, chat_id int not null, kind text not null, 
+        payload json not null default "", priority int not null default 0, status text not null default "pending", 
+        attempts int not null default 0, last_error text not null default "", next_attempt_at timestamp, date_create timestamp, 
+        source text not null default "", source_id int not null default 0);
+        CREATE INDEX IF NOT EXISTS outbox_status ON outbox (status, priority, next_attempt_at);`
+    deliveries := `CREATE TABLE IF NOT EXISTS deliveries (broadcast_id int not null, user_id int not null, status text not null, 
+        error text not null default "", message_id int not null default 0, date_create timestamp, unique (broadcast_id, user_id));`
+    broadcast_checkpoints := `CREATE TABLE IF NOT EXISTS broadcast_checkpoints (broadcast_id int not null unique, last_user_id int not null default 0);
+        CREATE UNIQUE INDEX IF NOT EXISTS outbox_broadcast_recipient ON outbox (source_id, chat_id) WHERE source = 'broadcast';`
+    campaigns := `CREATE TABLE IF NOT EXISTS campaigns (id integer primary key autoincrement, name text not null default "", 
+        from_chat_id int not null default 0, message_id int not null default 0, time_for_sent timestamp default 0, 
+        audience json not null default "", status text not null default "draft", created_by int not null default 0, date_create timestamp);`
+    recurring_broadcasts := `CREATE TABLE IF NOT EXISTS recurring_broadcasts (id integer primary key autoincrement, 
+        campaign_id int not null, schedule text not null, next_run timestamp default 0, last_run timestamp default 0, 
+        paused boolean not null default false, date_create timestamp);`
+    templates := `CREATE TABLE IF NOT EXISTS templates (id integer primary key autoincrement, name text not null default "", 
+        from_chat_id int not null default 0, message_id int not null default 0, content text not null default "", 
+        date_create timestamp);`
+    request_cards := `CREATE TABLE IF NOT EXISTS request_cards (request_id int not null, chat_id int not null, message_id int not null);
+        CREATE INDEX IF NOT EXISTS request_cards_request ON request_cards (request_id);`
+    screenings := `CREATE TABLE IF NOT EXISTS screenings (request_id int not null unique, user_id int not null, 
+        chat_id int not null default 0, user_chat_id int not null default 0, step int not null default 0, 
+        status text not null default "asking", answers json not null default "{}", date_create timestamp, date_update timestamp);
+        CREATE INDEX IF NOT EXISTS screenings_user ON screenings (user_id, status);`
+    captchas := `CREATE TABLE IF NOT EXISTS captchas (id integer primary key autoincrement, user_id int not null, 
+        chat_id int not null default 0, user_chat_id int not null default 0, event json not null default "", 
+        answer int not null default 0, message_id int not null default 0, status text not null default "waiting", 
+        deadline timestamp, date_create timestamp);
+        CREATE INDEX IF NOT EXISTS captchas_status ON captchas (status, deadline);`
+    join_rules := `CREATE TABLE IF NOT EXISTS join_rules (id integer primary key autoincrement, kind text not null, 
+        value text not null default "", date_create timestamp);`
+    join_decisions := `CREATE TABLE IF NOT EXISTS join_decisions (id integer primary key autoincrement, user_id int not null, 
+        chat_id int not null default 0, username text not null default "", request_date int not null default 0, 
+        decision text not null, 
+        rule text not null default "", date_create timestamp);
+        CREATE INDEX IF NOT EXISTS join_decisions_user ON join_decisions (user_id, date_create);
+        CREATE INDEX IF NOT EXISTS join_decisions_date ON join_decisions (date_create);`
+    query := users + messages + delays + requests_to_join + outbox + deliveries + broadcast_checkpoints + campaigns +
+        recurring_broadcasts + templates + request_cards + screenings + captchas +
+        join_rules + join_decisions
     _, err := s.db.ExecContext(
         ctx,
         query,
@@ -458,5 +531,73 @@ func (s *Storage) InitDbTables(ctx context.Context) error {
     if err != nil {
         return helpers.WrapErr(err, "cant init tables for db")
     }
+    return s.migrate(ctx)
+}
+
+// migrate updates tables created by previous versions of the bot
+func (s *Storage) migrate(ctx context.Context) error {
+    if err := s.addColumnIfNotExists(ctx, "broadcast_checkpoints", "finished", "boolean not null default false"); err != nil {
+        return err
+    }
+    if err := s.addColumnIfNotExists(ctx, "campaigns", "progress_chat_id", "int not null default 0"); err != nil {
+        return err
+    }
+    if err := s.addColumnIfNotExists(ctx, "campaigns", "progress_message_id", "int not null default 0"); err != nil {
+        return err
+    }
+    if err := s.addColumnIfNotExists(ctx, "campaigns", "ttl_seconds", "int not null default 0"); err != nil {
+        return err
+    }
+    if err := s.addColumnIfNotExists(ctx, "campaigns", "content", "text not null default ''"); err != nil {
+        return err
+    }
+    if err := s.addColumnIfNotExists(ctx, "messages", "content", "text not null default ''"); err != nil {
+        return err
+    }
+    if err := s.addColumnIfNotExists(ctx, "requests_to_join", "decided_by", "int not null default 0"); err != nil {
+        return err
+    }
+    if err := s.addColumnIfNotExists(ctx, "deliveries", "message_ids", "text not null default ''"); err != nil {
+        return err
+    }
+    if err := s.addColumnIfNotExists(ctx, "captchas", "decision_pending", "boolean not null default false"); err != nil {
+        return err
+    }
+    if err := s.addColumnIfNotExists(ctx, "captchas", "date_finish", "timestamp"); err != nil {
+        return err
+    }
+
+    // the single message for all users became a campaign
+    legacyBroadcast := `INSERT INTO campaigns (name, from_chat_id, message_id, time_for_sent, status, date_create) 
+        SELECT 'message ' || forward_message_id, chat_id, forward_message_id, time_for_sent, 
+        CASE WHEN time_for_sent = 0 THEN ? ELSE ? END, ? FROM messages WHERE key = ?;
+        DELETE FROM messages WHERE key = ?;`
+    _, err := s.db.ExecContext(
+        ctx,
+        legacyBroadcast,
+        storage.CampaignStatusDraft,
+        storage.CampaignStatusScheduled,
+        time.Now(),
+        storage.KeyAllMessage,
+        storage.KeyAllMessage,
+    )
+    if err != nil {
+        return helpers.WrapErr(err, "cant migrate message for all users to campaign")
+    }
+    return nil
+}
+
+func (s *Storage) addColumnIfNotExists(ctx context.Context, table string, column string, definition string) error {
+    var count int
+    query := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+    if err := s.db.QueryRowContext(ctx, query, table, column).Scan(&count); err != nil {
+        return helpers.WrapErr(err, "cant check column " + column + " in " + table)
+    }
+    if count > 0 {
+        return nil
+    }
+    if _, err := s.db.ExecContext(ctx, `ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition); err != nil {
+        return helpers.WrapErr(err, "cant add column " + column + " to " + table)
+    }
     return nil
 }
\ No newline at end of file
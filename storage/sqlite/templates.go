@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "strconv"
+    "time"
+    "user-handler-bot/helpers"
+    "user-handler-bot/storage"
+)
+
+const templateColumns = `id, name, from_chat_id, message_id, content, date_create`
+
+func (s *Storage) CreateTemplate(ctx context.Context, template storage.Template) (int, error) {
+    query := `INSERT INTO templates (name, from_chat_id, message_id, content, date_create) VALUES (?, ?, ?, ?, ?)`
+    res, err := s.db.ExecContext(ctx, query, template.Name, template.FromChatId, template.MessageId, template.Content, time.Now())
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant CreateTemplate " + template.Name)
+    }
+    id, err := res.LastInsertId()
+    if err != nil {
+        return 0, helpers.WrapErr(err, "cant CreateTemplate LastInsertId")
+    }
+    return int(id), nil
+}
+
+func (s *Storage) GetTemplate(ctx context.Context, id int) (storage.Template, error) {
+    query := `SELECT ` + templateColumns + ` FROM templates WHERE id = ?`
+    rows, err := s.db.QueryContext(ctx, query, id)
+    if err != nil {
+        return storage.Template{}, helpers.WrapErr(err, "cant GetTemplate " + strconv.Itoa(id))
+    }
+    templates, err := scanTemplates(rows)
+    if err != nil {
+        return storage.Template{}, helpers.WrapErr(err, "cant GetTemplate rows")
+    }
+    if len(templates) == 0 {
+        return storage.Template{}, helpers.WrapErr(sql.ErrNoRows, "cant GetTemplate " + strconv.Itoa(id))
+    }
+    return templates[0], nil
+}
+
+// GetTemplates returns templates sorted by name
+func (s *Storage) GetTemplates(ctx context.Context, limit int) ([]storage.Template, error) {
+    query := `SELECT ` + templateColumns + ` FROM templates ORDER BY name, id LIMIT ?`
+    rows, err := s.db.QueryContext(ctx, query, limit)
+    if err != nil {
+        return nil, helpers.WrapErr(err, "cant GetTemplates")
+    }
+    templates, err := scanTemplates(rows)
+    if err != nil {
+        return templates, helpers.WrapErr(err, "cant GetTemplates rows")
+    }
+    return templates, nil
+}
+
+func (s *Storage) UpdateTemplate(ctx context.Context, template storage.Template) error {
+    query := `UPDATE templates SET name = ?, from_chat_id = ?, message_id = ?, content = ? WHERE id = ?`
+    _, err := s.db.ExecContext(ctx, query, template.Name, template.FromChatId, template.MessageId, template.Content, template.Id)
+    if err != nil {
+        return helpers.WrapErr(err, "cant UpdateTemplate " + strconv.Itoa(template.Id))
+    }
+    return nil
+}
+
+func (s *Storage) DeleteTemplate(ctx context.Context, id int) error {
+    query := `DELETE FROM templates WHERE id = ?`
+    if _, err := s.db.ExecContext(ctx, query, id); err != nil {
+        return helpers.WrapErr(err, "cant DeleteTemplate " + strconv.Itoa(id))
+    }
+    return nil
+}
+
+func scanTemplates(rows *sql.Rows) ([]storage.Template, error) {
+    defer rows.Close()
+    var templates []storage.Template
+    for rows.Next() {
+        var template storage.Template
+        err := rows.Scan(
+            &template.Id,
+            &template.Name,
+            &template.FromChatId,
+            &template.MessageId,
+            &template.Content,
+            &template.CreatedAt,
+        )
+        if err != nil {
+            return templates, err
+        }
+        templates = append(templates, template)
+    }
+    return templates, rows.Err()
+}
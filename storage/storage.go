@@ -14,18 +14,90 @@ type Storage interface {
     DeleteUser(ctx context.Context, userId int) error
     IsUserExists(ctx context.Context, userId int) (bool, error)
     GetAllUsers(ctx context.Context) ([]User, error)
+    GetUsersAfter(ctx context.Context, audience Audience, lastUserId int, limit int) ([]User, error)
+    GetCountAudience(ctx context.Context, audience Audience) (int, error)
     GetCountUsersWithLastMsgId(ctx context.Context, lastMessageId int) (int, error)
     GetCountUsers(ctx context.Context) (int, error)
-    SaveMessage(ctx context.Context, messageId int, chatId int, key string) error
+    SaveMessage(ctx context.Context, messageId int, chatId int, content string, key string) error
     DeleteMessage(ctx context.Context, key string) error
     GetCurrentMessage(ctx context.Context, key string) (ForwardMessage, error)
     GetMessageForSend(ctx context.Context, key string) (ForwardMessage, error)
     SetTimeToSentForMessage(ctx context.Context, key string, date time.Time) error
     UpdateDelays(ctx context.Context, key string, delay int) error
     GetDelays(ctx context.Context, key string) (int, error)
-    SaveDelayedEventRequestToJoin(ctx context.Context, data []byte, delat int, autoAcceptStatus bool) error
+    SaveDelayedEventRequestToJoin(ctx context.Context, data []byte, delat int, autoAcceptStatus bool) (int, error)
     GetEventsWithDelayedMsgAfterRequestToJoin(ctx context.Context, autoAcceptStatus bool) ([]string, error)
     DeleteDelayedEventRequestToJoin(ctx context.Context, data []byte) error
+    GetPendingRequestsToJoin(ctx context.Context, offset int, limit int) ([]RequestToJoin, error)
+    GetCountPendingRequestsToJoin(ctx context.Context) (int, error)
+    GetRequestToJoin(ctx context.Context, id int) (RequestToJoin, bool, error)
+    ClaimRequestToJoin(ctx context.Context, id int, adminId int) (RequestToJoin, bool, error)
+    ReleaseRequestToJoin(ctx context.Context, id int) error
+    DeleteRequestToJoin(ctx context.Context, id int) error
+    SaveRequestCard(ctx context.Context, card RequestCard) error
+    GetRequestCards(ctx context.Context, requestId int) ([]RequestCard, error)
+    DeleteRequestCards(ctx context.Context, requestId int) error
+    SaveScreening(ctx context.Context, screening Screening) error
+    GetScreening(ctx context.Context, requestId int) (Screening, bool, error)
+    GetAskingScreening(ctx context.Context, userId int) (Screening, bool, error)
+    CreateCaptcha(ctx context.Context, captcha Captcha) (int, error)
+    GetCaptcha(ctx context.Context, id int) (Captcha, error)
+    SetCaptchaMessage(ctx context.Context, id int, messageId int) error
+    FinishCaptcha(ctx context.Context, id int, status string) (bool, error)
+    GetExpiredCaptchas(ctx context.Context) ([]Captcha, error)
+    SetCaptchaDecided(ctx context.Context, id int) error
+    GetUndecidedCaptchas(ctx context.Context, finishedBefore time.Time) ([]Captcha, error)
+    GetCaptchaStats(ctx context.Context) (map[string]int, error)
+    CreateJoinRule(ctx context.Context, rule JoinRule) (int, error)
+    GetJoinRules(ctx context.Context) ([]JoinRule, error)
+    DeleteJoinRule(ctx context.Context, id int) error
+    LogJoinDecision(ctx context.Context, decision JoinDecision) error
+    CountJoinDecisions(ctx context.Context, userId int, since time.Time, exceptRequestDate int) (int, error)
+    GetJoinDecisions(ctx context.Context, decision string, limit int) ([]JoinDecision, error)
+    DeleteJoinDecisionsBefore(ctx context.Context, before time.Time) error
+    EnqueueOutbox(ctx context.Context, item OutboxItem) error
+    EnqueueOutboxBatch(ctx context.Context, items []OutboxItem) error
+    ClaimOutbox(ctx context.Context, limit int) ([]OutboxItem, error)
+    MarkOutboxSent(ctx context.Context, id int) error
+    RescheduleOutbox(ctx context.Context, id int, lastError string, nextAttemptAt time.Time) error
+    MarkOutboxFailed(ctx context.Context, id int, lastError string) error
+    ResetSendingOutbox(ctx context.Context) error
+    RetryFailedOutbox(ctx context.Context) (int, error)
+    GetOutboxStats(ctx context.Context) (map[string]int, error)
+    GetFailedOutbox(ctx context.Context, limit int) ([]OutboxItem, error)
+    EnqueueBroadcastBatch(ctx context.Context, broadcastId int, items []OutboxItem, lastUserId int) (bool, error)
+    GetBroadcastCheckpoint(ctx context.Context, broadcastId int) (int, bool, error)
+    FinishBroadcastCheckpoint(ctx context.Context, broadcastId int) error
+    DeleteBroadcastCheckpoint(ctx context.Context, broadcastId int) error
+    GetOutboxSourceStats(ctx context.Context, source string, sourceId int) (map[string]int, error)
+    CreateCampaign(ctx context.Context, campaign Campaign) (int, error)
+    GetCampaign(ctx context.Context, id int) (Campaign, error)
+    GetCampaigns(ctx context.Context, statuses []string, limit int) ([]Campaign, error)
+    GetDueCampaigns(ctx context.Context) ([]Campaign, error)
+    UpdateCampaign(ctx context.Context, campaign Campaign) error
+    SwitchCampaignStatus(ctx context.Context, id int, from []string, to string) (bool, error)
+    PauseCampaign(ctx context.Context, id int) (bool, error)
+    ResumeCampaign(ctx context.Context, id int) (bool, error)
+    CancelCampaign(ctx context.Context, id int) (bool, error)
+    SetCampaignProgressMessage(ctx context.Context, id int, chatId int, messageId int) error
+    DeleteCampaign(ctx context.Context, id int) error
+    CreateRecurringBroadcast(ctx context.Context, recurring RecurringBroadcast) (int, error)
+    GetRecurringBroadcast(ctx context.Context, id int) (RecurringBroadcast, error)
+    GetRecurringBroadcasts(ctx context.Context) ([]RecurringBroadcast, error)
+    GetDueRecurringBroadcasts(ctx context.Context) ([]RecurringBroadcast, error)
+    StartRecurringBroadcast(ctx context.Context, recurring RecurringBroadcast, campaign Campaign, nextRun time.Time) (int, error)
+    SetRecurringBroadcastPaused(ctx context.Context, id int, paused bool, nextRun time.Time) error
+    DeleteRecurringBroadcast(ctx context.Context, id int) error
+    CreateTemplate(ctx context.Context, template Template) (int, error)
+    GetTemplate(ctx context.Context, id int) (Template, error)
+    GetTemplates(ctx context.Context, limit int) ([]Template, error)
+    UpdateTemplate(ctx context.Context, template Template) error
+    DeleteTemplate(ctx context.Context, id int) error
+    SaveDelivery(ctx context.Context, delivery Delivery) error
+    GetDeliveries(ctx context.Context, broadcastId int, statuses []string, limit int) ([]Delivery, error)
+    GetUserDeliveries(ctx context.Context, userId int) ([]Delivery, error)
+    GetDeliveryStats(ctx context.Context, broadcastId int) (map[string]int, error)
+    GetCountDeliveriesSince(ctx context.Context, broadcastId int, since time.Time) (int, error)
 }
 
 type User struct {
@@ -43,15 +115,233 @@ type ForwardMessage struct {
     FromChatId  int
     MessageId   int
     TimeToSent  time.Time
+    // Content is the message captured when it was set, it is sent instead of copying the admin message
+    Content     string
+}
+
+// OutboxItem is one outgoing message, Kind and Payload are defined by the sender
+type OutboxItem struct {
+    Id            int
+    ChatId        int
+    Kind          string
+    Payload       string
+    Priority      int
+    Status        string
+    Attempts      int
+    LastError     string
+    NextAttemptAt time.Time
+    CreatedAt     time.Time
+    Source        string
+    SourceId      int
+}
+
+// Campaign is a named broadcast of the message from an admin chat
+type Campaign struct {
+    Id         int
+    Name       string
+    FromChatId int
+    MessageId  int
+    // Content is the captured message like ForwardMessage.Content, empty for messages which can only be copied
+    Content    string
+    TimeToSent time.Time
+    Audience   Audience
+    Status     string
+    CreatedBy  int
+    CreatedAt  time.Time
+    // ProgressChatId is the admin chat where the progress of sending is shown in ProgressMessageId
+    ProgressChatId    int
+    ProgressMessageId int
+    // TTL is how long the message stays in users chats before the bot deletes it, 0 keeps it forever
+    TTL               time.Duration
+}
+
+// Audience filters recipients of a broadcast, empty fields do not filter
+type Audience struct {
+    ChannelId            string     `json:"channel_id,omitempty"`
+    JoinedFrom           *time.Time `json:"joined_from,omitempty"`
+    JoinedTo             *time.Time `json:"joined_to,omitempty"`
+    LeftChannels         *bool      `json:"left_channels,omitempty"`
+    ReceivedBroadcast    int        `json:"received_broadcast,omitempty"`
+    NotReceivedBroadcast int        `json:"not_received_broadcast,omitempty"`
+    HasUsername          *bool      `json:"has_username,omitempty"`
+}
+
+// Template is a named message which admins pick for campaigns and the message to request to join
+type Template struct {
+    Id         int
+    Name       string
+    FromChatId int
+    MessageId  int
+    Content    string
+    CreatedAt  time.Time
+}
+
+// RecurringBroadcast starts a new run of the campaign every time its schedule comes
+type RecurringBroadcast struct {
+    Id         int
+    CampaignId int
+    Schedule   string
+    NextRun    time.Time
+    LastRun    time.Time
+    Paused     bool
+    CreatedAt  time.Time
+}
+
+// Delivery is the result of sending a broadcast to the user, MessageIds keeps all messages of an album
+// and MessageId is the first of them
+type Delivery struct {
+    BroadcastId int
+    UserId      int
+    Status      string
+    Error       string
+    MessageId   int
+    MessageIds  []int
+    Timestamp   time.Time
 }
 
 type Message struct {
     Text    string
 }
 
+// RequestToJoin is a saved request to join which was not approved automatically, Event is the json of the event
+type RequestToJoin struct {
+    Id    int
+    Event string
+}
+
+// Screening is the questionnaire of the request to join, Step is the index of the next question
+// and Answers are kept by ids of questions
+type Screening struct {
+    UserId     int
+    ChatId     int
+    UserChatId int
+    RequestId  int
+    Step       int
+    Status     string
+    Answers    map[string]string
+    CreatedAt  time.Time
+    UpdatedAt  time.Time
+}
+
+// Captcha is a challenge for the user who sent a request to join before it is approved automatically,
+// Answer is the index of the right button and Event is the json of the request event
+type Captcha struct {
+    Id         int
+    UserId     int
+    ChatId     int
+    UserChatId int
+    Event      string
+    Answer     int
+    MessageId  int
+    Status     string
+    Deadline   time.Time
+    CreatedAt  time.Time
+}
+
+// JoinRule declines requests to join automatically, Value depends on Kind: a user id, a regexp,
+// a language code or a window of repeated requests like 24h
+type JoinRule struct {
+    Id        int
+    Kind      string
+    Value     string
+    CreatedAt time.Time
+}
+
+// JoinDecision is the log of a request to join, Rule tells what approved or declined it
+// and RequestDate is the unix time when the request was sent
+type JoinDecision struct {
+    Id          int
+    UserId      int
+    ChatId      int
+    Username    string
+    RequestDate int
+    Decision    string
+    Rule        string
+    CreatedAt   time.Time
+}
+
+// RequestCard is a message about the request to join sent to an admin, all cards are edited once the request is decided
+type RequestCard struct {
+    RequestId int
+    ChatId    int
+    MessageId int
+}
+
 const (
     KeyRequestMessage = "request_message"
+    // KeyAllMessage is kept to move the message for all users of old versions into campaigns
     KeyAllMessage = "message_all"
-    KeyLastMessageAll = "last_message_all"
     KeyDelayReqeustToJoin = "delay_request_to_join"
+    // KeyRequestMessageTTL keeps in delays how many seconds the welcome message stays in the user chat
+    KeyRequestMessageTTL = "request_message_ttl"
+    // KeyNotifyRequestToJoin keeps in delays 1 when admins get a card for every request to join which needs a decision
+    KeyNotifyRequestToJoin = "notify_request_to_join"
+    // KeyCaptchaDeadline keeps in delays how many seconds the user has to solve the captcha, 0 turns the captcha off
+    KeyCaptchaDeadline = "captcha_deadline"
+)
+
+const (
+    OutboxStatusPending = "pending"
+    OutboxStatusSending = "sending"
+    OutboxStatusSent = "sent"
+    OutboxStatusFailed = "failed"
+    OutboxStatusPaused = "paused"
+    OutboxStatusCancelled = "cancelled"
+
+    OutboxSourceBroadcast = "broadcast"
+    OutboxSourceWelcome = "welcome"
+    OutboxSourceRecall = "recall"
+    OutboxSourceEdit = "edit"
+    // OutboxSourceExpire deletes a broadcast message whose TTL has passed
+    OutboxSourceExpire = "expire"
+)
+
+const (
+    ScreeningStatusAsking = "asking"
+    ScreeningStatusApproved = "approved"
+    ScreeningStatusReview = "review"
+    // ScreeningStatusClosed means admins decided the request before the user answered all questions
+    ScreeningStatusClosed = "closed"
+)
+
+const (
+    CaptchaStatusWaiting = "waiting"
+    CaptchaStatusPassed = "passed"
+    CaptchaStatusFailed = "failed"
+    CaptchaStatusExpired = "expired"
+)
+
+const (
+    JoinRuleUserId = "user_id"
+    JoinRuleUsername = "username"
+    JoinRuleFirstName = "first_name"
+    JoinRuleNoUsername = "no_username"
+    JoinRuleLanguage = "language"
+    JoinRuleRepeated = "repeated"
+    // JoinRuleBanned matches users who are banned in the channel of the request
+    JoinRuleBanned = "banned"
+
+    JoinDecisionPassed = "passed"
+    JoinDecisionApproved = "approved"
+    JoinDecisionDeclined = "declined"
+)
+
+const (
+    DeliveryStatusSent = "sent"
+    DeliveryStatusBlocked = "blocked"
+    DeliveryStatusChatNotFound = "chat_not_found"
+    DeliveryStatusRateLimited = "rate_limited"
+    DeliveryStatusFailed = "failed"
+    // DeliveryStatusDeleted is a sent message which was deleted from the user chat later
+    DeliveryStatusDeleted = "deleted"
+)
+
+const (
+    CampaignStatusDraft = "draft"
+    CampaignStatusAwaitingConfirmation = "awaiting_confirmation"
+    CampaignStatusScheduled = "scheduled"
+    CampaignStatusSending = "sending"
+    CampaignStatusPaused = "paused"
+    CampaignStatusDone = "done"
+    CampaignStatusCancelled = "cancelled"
 )
\ No newline at end of file